@@ -5,10 +5,12 @@ use Exception;
 class B9m {
     private $baseUrl;
     private $apiKey;
+    private $profile;
 
-    public function __construct($baseUrl, $apiKey) {
+    public function __construct($baseUrl, $apiKey, $profile = null) {
         $this->baseUrl = rtrim($baseUrl, '/');
         $this->apiKey = $apiKey;
+        $this->profile = $profile;
     }
 
     private function request($method, $endpoint, $data = []) {
@@ -19,6 +21,9 @@ class B9m {
             'Content-Type: application/json',
             'Authorization: Bearer ' . $this->apiKey
         ];
+        if ($this->profile !== null) {
+            $headers[] = 'X-B9M-Profile: ' . $this->profile;
+        }
         
         $options = [
             CURLOPT_RETURNTRANSFER => true,
@@ -30,7 +35,7 @@ class B9m {
             CURLOPT_TIMEOUT => 3,
         ];
         
-        if ($method === 'POST' || $method === 'PUT') {
+        if ($method === 'POST' || $method === 'PUT' || $method === 'PATCH') {
             $options[CURLOPT_POSTFIELDS] = json_encode($data);
         }
         
@@ -52,33 +57,155 @@ class B9m {
         return $decodedResponse;
     }
 
-    public function addDomain($domain, $ns1, $ns2) {
+    // $view selects the view a zone belongs to; null means zones outside of views.
+    private function viewQuery($view) {
+        return $view === null ? '' : '?view=' . urlencode($view);
+    }
+
+    // $template names the zone template the zone file is created from;
+    // getTemplates() lists them. $nameservers adds nameservers after $ns1 and
+    // $ns2, and $soa takes the same keys as setSOA().
+    public function addDomain($domain, $ns1, $ns2, $serialScheme = 'date', $view = null, $template = null, $soa = null, $nameservers = []) {
         return $this->request('POST', 'domains', [
-            'domain' => $domain,
-            'ns1'    => $ns1,
-            'ns2'    => $ns2
+            'domain'        => $domain,
+            'ns1'           => $ns1 ?? '',
+            'ns2'           => $ns2 ?? '',
+            'nameservers'   => $nameservers,
+            'serial_scheme' => $serialScheme,
+            'view'          => $view ?? '',
+            'template'      => $template ?? '',
+            'soa'           => (object) ($soa ?? [])
+        ]);
+    }
+
+    // $soa may hold 'contact' (an email address), 'refresh', 'retry',
+    // 'expire', 'minimum' and 'default_ttl'; missing keys are left unchanged.
+    public function setSOA($domain, $soa, $view = null) {
+        return $this->request('PATCH', "domains/$domain/soa" . $this->viewQuery($view), $soa);
+    }
+
+    // Adds the in-addr.arpa or ip6.arpa domains covering $cidr. For RFC 2317
+    // classless networks such as 192.0.2.64/26, the response lists the
+    // delegation records to add to the parent zone when it is not managed here.
+    public function addReverseDomain($cidr, $ns1, $ns2, $serialScheme = 'date', $view = null, $template = null, $soa = null, $nameservers = []) {
+        return $this->request('POST', 'reverse-domains', [
+            'cidr'          => $cidr,
+            'ns1'           => $ns1 ?? '',
+            'ns2'           => $ns2 ?? '',
+            'nameservers'   => $nameservers,
+            'serial_scheme' => $serialScheme,
+            'view'          => $view ?? '',
+            'template'      => $template ?? '',
+            'soa'           => (object) ($soa ?? [])
+        ]);
+    }
+
+    // $primaries is a list of addresses, e.g. ['192.0.2.1', '192.0.2.2 port 5353'].
+    public function addSecondary($domain, $primaries, $key = null, $transferSource = null, $view = null) {
+        return $this->addTransferred('secondaries', $domain, $primaries, $key, $transferSource, $view);
+    }
+
+    public function updateSecondary($domain, $primaries, $key = null, $transferSource = null, $view = null) {
+        return $this->updateTransferred('secondaries', $domain, $primaries, $key, $transferSource, $view);
+    }
+
+    public function addStub($domain, $primaries, $key = null, $transferSource = null, $view = null) {
+        return $this->addTransferred('stubs', $domain, $primaries, $key, $transferSource, $view);
+    }
+
+    public function updateStub($domain, $primaries, $key = null, $transferSource = null, $view = null) {
+        return $this->updateTransferred('stubs', $domain, $primaries, $key, $transferSource, $view);
+    }
+
+    private function addTransferred($endpoint, $domain, $primaries, $key, $transferSource, $view) {
+        return $this->request('POST', $endpoint, [
+            'domain'          => $domain,
+            'primaries'       => $primaries,
+            'key'             => $key ?? '',
+            'transfer_source' => $transferSource ?? '',
+            'view'            => $view ?? ''
         ]);
     }
 
-    public function deleteDomain($domain) {
-        return $this->request('DELETE', "domains/$domain");
+    private function updateTransferred($endpoint, $domain, $primaries, $key, $transferSource, $view) {
+        return $this->request('PUT', "$endpoint/$domain" . $this->viewQuery($view), [
+            'primaries'       => $primaries,
+            'key'             => $key ?? '',
+            'transfer_source' => $transferSource ?? ''
+        ]);
     }
 
-    public function addRecord($domain, $name, $type, $value, $ttl) {
-        return $this->request('POST', "domains/$domain/records", [
+    // $forward is 'only', 'first' or null for named's default.
+    public function addForward($domain, $forwarders, $forward = null, $view = null) {
+        return $this->request('POST', 'forwards', [
+            'domain'     => $domain,
+            'forwarders' => $forwarders,
+            'forward'    => $forward ?? '',
+            'view'       => $view ?? ''
+        ]);
+    }
+
+    public function updateForward($domain, $forwarders, $forward = null, $view = null) {
+        return $this->request('PUT', "forwards/$domain" . $this->viewQuery($view), [
+            'forwarders' => $forwarders,
+            'forward'    => $forward ?? ''
+        ]);
+    }
+
+    public function deleteDomain($domain, $view = null) {
+        return $this->request('DELETE', "domains/$domain" . $this->viewQuery($view));
+    }
+
+    // $value may be a plain string, a list of strings for TXT records, or an
+    // array of structured fields,
+    // e.g. ['priority' => 10, 'weight' => 5, 'port' => 5060, 'target' => 'sip.example.com.'] for SRV.
+    // With $syncPtr, A and AAAA records also get a PTR record in the reverse
+    // domain covering their address; the same applies to update and delete.
+    // Adding and updating return the resulting record, with its ID, under 'record'.
+    public function addRecord($domain, $name, $type, $value, $ttl, $view = null, $syncPtr = false) {
+        return $this->request('POST', "domains/$domain/records" . $this->viewQuery($view), [
             'name'  => $name,
             'type'  => $type,
-            'value' => $value,
-            'ttl'   => $ttl
+            $this->valueKey($value) => $value,
+            'ttl'   => $ttl,
+            'ptr'   => $syncPtr
         ]);
     }
 
-    public function deleteRecord($domain, $name, $type, $value) {
-        return $this->request('DELETE', "domains/$domain/records/$name/$type/$value");
+    public function deleteRecord($domain, $id, $view = null, $syncPtr = false) {
+        $query = $this->viewQuery($view);
+        if ($syncPtr) {
+            $query .= ($query === '' ? '?' : '&') . 'ptr=true';
+        }
+        return $this->request('DELETE', "domains/$domain/records/$id" . $query);
     }
 
-    public function getAllRecords($domain) {
-        return $this->request('GET', "domains/$domain/records");
+    public function getRecord($domain, $id, $view = null) {
+        return $this->request('GET', "domains/$domain/records/$id" . $this->viewQuery($view));
+    }
+
+    public function updateRecord($domain, $id, $newValue, $ttl, $view = null, $syncPtr = false) {
+        return $this->request('PUT', "domains/$domain/records/$id" . $this->viewQuery($view), [
+            $this->valueKey($newValue) => $newValue,
+            'ttl'   => $ttl,
+            'ptr'   => $syncPtr
+        ]);
+    }
+
+    public function getAllRecords($domain, $view = null) {
+        return $this->request('GET', "domains/$domain/records" . $this->viewQuery($view));
+    }
+
+    private function valueKey($value) {
+        return is_array($value) && !array_is_list($value) ? 'data' : 'value';
+    }
+
+    public function lintZone($domain, $view = null, $strict = false) {
+        $query = $this->viewQuery($view);
+        if ($strict) {
+            $query .= ($query === '' ? '?' : '&') . 'strict=true';
+        }
+        return $this->request('GET', "domains/$domain/lint" . $query);
     }
 
     public function reload() {
@@ -104,4 +231,12 @@ class B9m {
     public function getDomains() {
         return $this->request('GET', 'domains');
     }
+
+    public function getTemplates() {
+        return $this->request('GET', 'templates');
+    }
+
+    public function lintTemplate($name) {
+        return $this->request('GET', "templates/" . urlencode($name) . "/lint");
+    }
 }
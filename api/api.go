@@ -1,10 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/AfazTech/b9m/config"
 	"github.com/AfazTech/b9m/parser"
 	"github.com/AfazTech/b9m/record"
 	"github.com/AfazTech/b9m/servicemanager"
@@ -14,11 +16,14 @@ import (
 )
 
 type API struct {
-	apiKey string
+	apiKey    string
+	instances *config.Instances
 }
 
-func NewAPI(apiKey string) *API {
-	return &API{apiKey: apiKey}
+const ProfileHeader = "X-B9M-Profile"
+
+func NewAPI(apiKey string, instances *config.Instances) *API {
+	return &API{apiKey: apiKey, instances: instances}
 }
 
 func (api *API) authMiddleware(c *gin.Context) {
@@ -30,14 +35,54 @@ func (api *API) authMiddleware(c *gin.Context) {
 	c.Next()
 }
 
+// profileMiddleware resolves the instance a request applies to, taken from
+// the /profiles/:profile path prefix or the X-B9M-Profile header.
+func (api *API) profileMiddleware(c *gin.Context) {
+	name := c.Param("profile")
+	if name == "" {
+		name = c.GetHeader(ProfileHeader)
+	}
+	inst, err := api.instances.Get(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"ok": false, "message": err.Error()})
+		c.Abort()
+		return
+	}
+	c.Set("instance", inst)
+	c.Next()
+}
+
+func instance(c *gin.Context) *config.Instance {
+	return c.MustGet("instance").(*config.Instance)
+}
+
 func (api *API) SetupRoutes(router *gin.Engine) {
 	router.Use(api.authMiddleware)
+	api.setupInstanceRoutes(router.Group("/", api.profileMiddleware))
+	api.setupInstanceRoutes(router.Group("/profiles/:profile", api.profileMiddleware))
+}
+
+func (api *API) setupInstanceRoutes(router *gin.RouterGroup) {
 	router.POST("/domains", api.AddDomain)
 	router.DELETE("/domains/:domain", api.DeleteDomain)
+	router.PATCH("/domains/:domain/soa", api.UpdateSOA)
+	router.POST("/reverse-domains", api.AddReverseDomain)
+	router.POST("/secondaries", api.addTransferredZone(zone.AddSecondary))
+	router.PUT("/secondaries/:domain", api.updateTransferredZone(zone.UpdateSecondary))
+	router.POST("/stubs", api.addTransferredZone(zone.AddStub))
+	router.PUT("/stubs/:domain", api.updateTransferredZone(zone.UpdateStub))
+	router.POST("/forwards", api.AddForward)
+	router.PUT("/forwards/:domain", api.UpdateForward)
 	router.POST("/domains/:domain/records", api.AddRecord)
-	router.DELETE("/domains/:domain/records/:name/:type/:value", api.DeleteRecord)
 	router.GET("/domains/:domain/records", api.GetAllRecords)
+	router.GET("/domains/:domain/lint", api.LintZone)
+	router.GET("/domains/:domain/records/:id", api.GetRecord)
+	router.PUT("/domains/:domain/records/:id", api.UpdateRecord)
+	router.PATCH("/domains/:domain/records/:id", api.UpdateRecord)
+	router.DELETE("/domains/:domain/records/:id", api.DeleteRecord)
 	router.GET("/domains", api.GetDomains)
+	router.GET("/templates", api.GetTemplates)
+	router.GET("/templates/:name/lint", api.LintTemplate)
 	router.POST("/reload", api.ReloadBind)
 	router.POST("/restart", api.RestartBind)
 	router.POST("/stop", api.StopBind)
@@ -46,7 +91,7 @@ func (api *API) SetupRoutes(router *gin.Engine) {
 }
 
 func (api *API) ReloadBind(c *gin.Context) {
-	err := servicemanager.ReloadBind()
+	err := servicemanager.ReloadBind(instance(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -55,7 +100,7 @@ func (api *API) ReloadBind(c *gin.Context) {
 }
 
 func (api *API) RestartBind(c *gin.Context) {
-	err := servicemanager.RestartBind()
+	err := servicemanager.RestartBind(instance(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -64,7 +109,7 @@ func (api *API) RestartBind(c *gin.Context) {
 }
 
 func (api *API) StopBind(c *gin.Context) {
-	err := servicemanager.StopBind()
+	err := servicemanager.StopBind(instance(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -73,7 +118,7 @@ func (api *API) StopBind(c *gin.Context) {
 }
 
 func (api *API) StartBind(c *gin.Context) {
-	err := servicemanager.StartBind()
+	err := servicemanager.StartBind(instance(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -82,7 +127,7 @@ func (api *API) StartBind(c *gin.Context) {
 }
 
 func (api *API) StatusBind(c *gin.Context) {
-	status, err := servicemanager.StatusBind()
+	status, err := servicemanager.StatusBind(instance(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -93,8 +138,8 @@ func (api *API) StatusBind(c *gin.Context) {
 func (api *API) AddDomain(c *gin.Context) {
 	var input struct {
 		Domain string `json:"domain" binding:"required"`
-		NS1    string `json:"ns1" binding:"required"`
-		NS2    string `json:"ns2" binding:"required"`
+		View   string `json:"view"`
+		domainInput
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -102,7 +147,7 @@ func (api *API) AddDomain(c *gin.Context) {
 		return
 	}
 
-	err := zone.AddDomain(input.Domain, input.NS1, input.NS2)
+	err := zone.AddDomain(instance(c), input.View, input.Domain, input.options())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -111,9 +156,153 @@ func (api *API) AddDomain(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"ok": true, "message": "Domain added successfully"})
 }
 
+// domainInput is the part of the body shared by the routes adding primary
+// domains. ns1 and ns2 are kept for older clients and come before the
+// nameservers list.
+type domainInput struct {
+	NS1 string `json:"ns1"`
+	NS2 string `json:"ns2"`
+	zone.DomainOptions
+}
+
+func (in domainInput) options() zone.DomainOptions {
+	opts := in.DomainOptions
+	var nameservers []string
+	for _, ns := range []string{in.NS1, in.NS2} {
+		if ns != "" {
+			nameservers = append(nameservers, ns)
+		}
+	}
+	opts.Nameservers = append(nameservers, opts.Nameservers...)
+	return opts
+}
+
+func (api *API) AddReverseDomain(c *gin.Context) {
+	var input struct {
+		CIDR string `json:"cidr" binding:"required"`
+		View string `json:"view"`
+		domainInput
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
+	result, err := zone.AddReverseDomain(instance(c), input.View, input.CIDR, input.options())
+	if err != nil {
+		resp := gin.H{"ok": false, "message": err.Error()}
+		if len(result.Domains) > 0 {
+			resp["domains"] = result.Domains
+		}
+		c.JSON(http.StatusInternalServerError, resp)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "message": "Reverse domains added successfully", "domains": result.Domains, "delegation": result.Delegation})
+}
+
+func (api *API) UpdateSOA(c *gin.Context) {
+	var input parser.SOAOptions
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
+	domain := c.Param("domain")
+	if err := record.SetSOA(instance(c), c.Query("view"), domain, input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "message": "SOA updated successfully"})
+}
+
+// addTransferredZone handles adding secondary and stub zones, which take
+// the same input.
+func (api *API) addTransferredZone(add func(*config.Instance, string, string, zone.SecondaryOptions) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Domain string `json:"domain" binding:"required"`
+			View   string `json:"view"`
+			zone.SecondaryOptions
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+			return
+		}
+
+		if err := add(instance(c), input.View, input.Domain, input.SecondaryOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"ok": true, "message": "Domain added successfully"})
+	}
+}
+
+func (api *API) updateTransferredZone(update func(*config.Instance, string, string, zone.SecondaryOptions) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input zone.SecondaryOptions
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+			return
+		}
+
+		if err := update(instance(c), c.Query("view"), c.Param("domain"), input); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"ok": true, "message": "Domain updated successfully"})
+	}
+}
+
+func (api *API) AddForward(c *gin.Context) {
+	var input struct {
+		Domain string `json:"domain" binding:"required"`
+		View   string `json:"view"`
+		zone.ForwardOptions
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
+	err := zone.AddForward(instance(c), input.View, input.Domain, input.ForwardOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "message": "Forward domain added successfully"})
+}
+
+func (api *API) UpdateForward(c *gin.Context) {
+	var input zone.ForwardOptions
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
+	domain := c.Param("domain")
+	err := zone.UpdateForward(instance(c), c.Query("view"), domain, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "message": "Forward domain updated successfully"})
+}
+
 func (api *API) DeleteDomain(c *gin.Context) {
 	domain := c.Param("domain")
-	err := zone.DeleteDomain(domain)
+	err := zone.DeleteDomain(instance(c), c.Query("view"), domain)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -126,8 +315,10 @@ func (api *API) AddRecord(c *gin.Context) {
 	var input struct {
 		Name  string            `json:"name" binding:"required"`
 		Type  record.RecordType `json:"type" binding:"required"`
-		Value string            `json:"value" binding:"required"`
+		Value json.RawMessage   `json:"value"`
+		Data  json.RawMessage   `json:"data"`
 		TTL   string            `json:"ttl" binding:"required"`
+		PTR   bool              `json:"ptr"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -141,32 +332,125 @@ func (api *API) AddRecord(c *gin.Context) {
 		return
 	}
 
+	value, err := recordValue(input.Type, input.Value, input.Data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+
 	domain := c.Param("domain")
-	err = record.AddRecord(domain, input.Type, input.Name, input.Value, ttl)
+	rec, err := record.AddRecord(instance(c), c.Query("view"), domain, input.Type, input.Name, value, ttl, input.PTR)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"ok": true, "message": "Record added successfully"})
+	c.JSON(http.StatusCreated, gin.H{"ok": true, "message": "Record added successfully", "record": rec})
 }
 
 func (api *API) DeleteRecord(c *gin.Context) {
 	domain := c.Param("domain")
-	name := c.Param("name")
-	rType := c.Param("type")
-	value := c.Param("value")
-	err := record.DeleteRecord(domain, name, record.RecordType(rType), value)
+	id := c.Param("id")
+	err := record.DeleteRecordByID(instance(c), c.Query("view"), domain, id, c.Query("ptr") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"ok": true, "message": fmt.Sprintf("Record deleted successfully: Domain: '%s', Name: '%s', Type: '%s', Value: '%s'.", domain, name, rType, value)})
+	c.JSON(http.StatusOK, gin.H{"ok": true, "message": fmt.Sprintf("Record deleted successfully: Domain: '%s', ID: '%s'.", domain, id)})
+}
+
+func (api *API) GetRecord(c *gin.Context) {
+	domain := c.Param("domain")
+	id := c.Param("id")
+	rec, err := record.GetRecord(instance(c), c.Query("view"), domain, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "record": rec})
+}
+
+func (api *API) UpdateRecord(c *gin.Context) {
+	var input struct {
+		Value json.RawMessage `json:"value"`
+		Data  json.RawMessage `json:"data"`
+		TTL   string          `json:"ttl"`
+		PTR   bool            `json:"ptr"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+	if c.Request.Method == http.MethodPut && ((len(input.Value) == 0 && len(input.Data) == 0) || input.TTL == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": "value or data, and ttl are required"})
+		return
+	}
+
+	domain := c.Param("domain")
+	id := c.Param("id")
+	value := ""
+	if len(input.Value) > 0 || len(input.Data) > 0 {
+		rec, err := record.GetRecord(instance(c), c.Query("view"), domain, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"ok": false, "message": err.Error()})
+			return
+		}
+		if value, err = recordValue(rec.Type, input.Value, input.Data); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": err.Error()})
+			return
+		}
+	}
+
+	ttl := 0
+	if input.TTL != "" {
+		var err error
+		ttl, err = strconv.Atoi(input.TTL)
+		if err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"ok": false, "message": "ttl must be a valid positive integer"})
+			return
+		}
+	}
+
+	rec, err := record.UpdateRecordByID(instance(c), c.Query("view"), domain, id, value, ttl, input.PTR)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "message": fmt.Sprintf("Record updated successfully: Domain: '%s', ID: '%s'.", domain, rec.ID), "record": rec})
+}
+
+// recordValue returns the rdata of a record given either as a plain value,
+// a list of strings for TXT records, or structured data fields.
+func recordValue(rType record.RecordType, value, data json.RawMessage) (string, error) {
+	switch {
+	case len(value) > 0 && len(data) > 0:
+		return "", fmt.Errorf("value and data are mutually exclusive")
+	case len(data) > 0:
+		return record.FormatData(rType, data)
+	case len(value) == 0:
+		return "", fmt.Errorf("value or data is required")
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if s == "" {
+			return "", fmt.Errorf("value or data is required")
+		}
+		return s, nil
+	}
+	var list []string
+	if err := json.Unmarshal(value, &list); err != nil {
+		return "", fmt.Errorf("value must be a string or a list of strings")
+	}
+	if rType != record.TXT {
+		return "", fmt.Errorf("only TXT records accept a list of strings as value")
+	}
+	return parser.TXTData{Segments: list}.String(), nil
 }
 
 func (api *API) GetAllRecords(c *gin.Context) {
 	domain := c.Param("domain")
-	records, err := record.GetAllRecords(domain)
+	records, err := record.GetAllRecords(instance(c), c.Query("view"), domain)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
@@ -175,18 +459,55 @@ func (api *API) GetAllRecords(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true, "records": records})
 }
 
+func (api *API) LintZone(c *gin.Context) {
+	domain := c.Param("domain")
+	diags, err := zone.CheckZone(instance(c), c.Query("view"), domain, c.Query("strict") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+	if diags == nil {
+		diags = []parser.Diagnostic{}
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "valid": parser.FirstError(diags) == nil, "diagnostics": diags})
+}
+
 func (api *API) GetDomains(c *gin.Context) {
-	domains, err := parser.GetDomains()
+	domains, err := zone.GetDomains(instance(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
 		return
 	}
 
+	if domains == nil {
+		domains = []parser.Domain{}
+	}
 	c.JSON(http.StatusOK, gin.H{"ok": true, "domains": domains})
 }
 
-func StartServer(port string, apiKey string) {
-	api := NewAPI(apiKey)
+func (api *API) GetTemplates(c *gin.Context) {
+	templates, err := zone.Templates(instance(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "templates": templates})
+}
+
+func (api *API) LintTemplate(c *gin.Context) {
+	diags, err := zone.CheckTemplate(instance(c), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"ok": false, "message": err.Error()})
+		return
+	}
+	if diags == nil {
+		diags = []parser.Diagnostic{}
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "valid": parser.FirstError(diags) == nil, "diagnostics": diags})
+}
+
+func StartServer(port string, apiKey string, instances *config.Instances) {
+	api := NewAPI(apiKey, instances)
 	router := gin.Default()
 	api.SetupRoutes(router)
 
@@ -0,0 +1,208 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AfazTech/b9m/parser"
+)
+
+const maxTTL = 2147483647
+
+type Error struct {
+	Subject  string
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed validation: %s", e.Subject, strings.Join(e.Problems, "; "))
+}
+
+type report struct {
+	problems []string
+}
+
+func (r *report) add(format string, args ...interface{}) {
+	r.problems = append(r.problems, fmt.Sprintf(format, args...))
+}
+
+func (r *report) err(subject string) error {
+	if len(r.problems) == 0 {
+		return nil
+	}
+	return &Error{Subject: subject, Problems: r.problems}
+}
+
+type nsRecord struct {
+	owner  string
+	target string
+}
+
+func inZone(name, origin string) bool {
+	name, origin = strings.ToLower(name), strings.ToLower(origin)
+	return name == origin || strings.HasSuffix(name, "."+origin)
+}
+
+// CheckZone validates zone contents the way named-checkzone would before
+// the zone is handed to named. The apex is always domain; relative names are
+// resolved against the origin of their record, or the apex if it has none.
+func CheckZone(domain string, zone parser.ZoneData) error {
+	origin := strings.ToLower(strings.TrimSuffix(domain, ".") + ".")
+	r := &report{}
+
+	var owners []string
+	types := map[string]map[string]int{}
+	addresses := map[string]bool{}
+	var nsRecords []nsRecord
+	soaCount := 0
+
+	for _, rec := range zone.Records {
+		owner := strings.ToLower(parser.Fqdn(rec.Name, origin))
+		if !inZone(owner, origin) {
+			r.add("%s %s: out of zone data", owner, rec.Type)
+			continue
+		}
+		if rec.TTL < 0 || rec.TTL > maxTTL {
+			r.add("%s %s: TTL %d out of range", owner, rec.Type, rec.TTL)
+		}
+		if types[owner] == nil {
+			types[owner] = map[string]int{}
+			owners = append(owners, owner)
+		}
+		types[owner][rec.Type]++
+		switch rec.Type {
+		case "SOA":
+			soaCount++
+			if owner != origin {
+				r.add("%s: SOA record not at zone apex", owner)
+			}
+		case "A", "AAAA":
+			addresses[owner] = true
+		case "NS":
+			rdataOrigin := origin
+			if rec.Origin != "" {
+				rdataOrigin = rec.Origin
+			}
+			target := strings.ToLower(parser.Fqdn(rec.RData, rdataOrigin))
+			nsRecords = append(nsRecords, nsRecord{owner: owner, target: target})
+		}
+	}
+
+	switch {
+	case soaCount == 0:
+		r.add("%s: no SOA record at zone apex", origin)
+	case soaCount > 1:
+		r.add("%s: multiple SOA records", origin)
+	}
+	if types[origin]["NS"] == 0 {
+		r.add("%s: no NS records at zone apex", origin)
+	}
+	for _, owner := range owners {
+		counts := types[owner]
+		if counts["CNAME"] == 0 {
+			continue
+		}
+		if counts["CNAME"] > 1 {
+			r.add("%s: multiple CNAME records", owner)
+		}
+		var others []string
+		for t := range counts {
+			if t != "CNAME" && t != "RRSIG" && t != "NSEC" {
+				others = append(others, t)
+			}
+		}
+		if len(others) > 0 {
+			sort.Strings(others)
+			r.add("%s: CNAME and other data (%s)", owner, strings.Join(others, ", "))
+		}
+	}
+	for _, ns := range nsRecords {
+		if inZone(ns.target, origin) && !addresses[ns.target] {
+			r.add("%s NS %s: in-zone nameserver has no address records (missing glue)", ns.owner, ns.target)
+		}
+	}
+	return r.err("zone " + strings.TrimSuffix(origin, "."))
+}
+
+var primaryTypes = map[string]bool{"master": true, "primary": true}
+var secondaryTypes = map[string]bool{"slave": true, "secondary": true, "mirror": true, "stub": true}
+
+// CheckConfig validates the parsed configuration tree the way
+// named-checkconf would, including edits not yet written to disk.
+func CheckConfig(c *parser.Config) error {
+	r := &report{}
+
+	if options := c.FindAll("options"); len(options) > 1 {
+		r.add("%s: options cannot be redefined", options[1].Pos)
+	}
+
+	keys := map[string]bool{}
+	for _, k := range c.Keys() {
+		if keys[k.Name] {
+			r.add("%s: key %s redefined", k.Stmt.Pos, k.Name)
+		}
+		keys[k.Name] = true
+		if k.Algorithm == "" || k.Secret == "" {
+			r.add("%s: key %s needs both algorithm and secret", k.Stmt.Pos, k.Name)
+		}
+	}
+
+	views := map[string]bool{}
+	for _, v := range c.Views() {
+		if views[v.Name] {
+			r.add("%s: view %s redefined", v.Stmt.Pos, v.Name)
+		}
+		views[v.Name] = true
+	}
+
+	seen := map[string]*parser.Zone{}
+	files := map[string]*parser.Zone{}
+	for _, z := range c.Zones() {
+		pos := z.Stmt.Pos
+		if len(views) > 0 && z.View == "" {
+			r.add("%s: zone %s: when using view statements, all zones must be in views", pos, z.Name)
+		}
+		id := z.View + "/" + strings.ToLower(z.Name)
+		if prev, ok := seen[id]; ok {
+			r.add("%s: zone %s already defined at %s", pos, z.Name, prev.Stmt.Pos)
+		}
+		seen[id] = z
+		switch {
+		case z.Type == "":
+			if z.Stmt.Find("in-view") == nil {
+				r.add("%s: zone %s: missing type", pos, z.Name)
+			}
+		case primaryTypes[z.Type]:
+			if z.File == "" {
+				r.add("%s: zone %s: primary zones require a file", pos, z.Name)
+			}
+		case secondaryTypes[z.Type]:
+			if len(z.Primaries) == 0 {
+				r.add("%s: zone %s: %s zones require a primaries list", pos, z.Name, z.Type)
+			}
+		case z.Type == "forward":
+			if z.File != "" {
+				r.add("%s: zone %s: forward zones cannot have a file", pos, z.Name)
+			}
+		}
+		if z.Forward != "" && z.Forward != "only" && z.Forward != "first" {
+			r.add("%s: zone %s: forward must be only or first, not %s", pos, z.Name, z.Forward)
+		}
+		if z.File != "" && primaryTypes[z.Type] {
+			if prev, ok := files[z.File]; ok {
+				r.add("%s: zone %s: file %s already used by zone %s", pos, z.Name, z.File, prev.Name)
+			}
+			files[z.File] = z
+		}
+		for _, element := range z.Primaries {
+			fields := strings.Fields(element)
+			for i := 0; i+1 < len(fields); i++ {
+				if fields[i] == "key" && !keys[strings.Trim(fields[i+1], `"`)] {
+					r.add("%s: zone %s: unknown key %s", pos, z.Name, fields[i+1])
+				}
+			}
+		}
+	}
+	return r.err("configuration")
+}
@@ -0,0 +1,97 @@
+package checker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AfazTech/b9m/parser"
+)
+
+const apex = `$TTL 3600
+@ IN SOA ns1.example.com. admin.example.com. 1 7200 3600 1209600 3600
+@ IN NS ns1.example.com.
+ns1 IN A 192.0.2.1
+`
+
+func TestCheckZone(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+		want []string
+	}{
+		{"valid", apex + "www IN CNAME ns1\n", nil},
+		{"no SOA", "@ 3600 IN NS ns1.example.net.\n", []string{"example.com.: no SOA record at zone apex"}},
+		{"no NS", "@ 3600 IN SOA ns1.example.net. admin.example.com. 1 2 3 4 5\n", []string{"example.com.: no NS records at zone apex"}},
+		{"multiple SOA", apex + "@ IN SOA ns1.example.com. admin.example.com. 2 7200 3600 1209600 3600\n", []string{"example.com.: multiple SOA records"}},
+		{"CNAME and other data", apex + "www IN CNAME ns1\nwww IN A 192.0.2.2\n", []string{"www.example.com.: CNAME and other data (A)"}},
+		{"out of zone", apex + "www.example.org. IN A 192.0.2.2\n", []string{"www.example.org. A: out of zone data"}},
+		{"missing glue", apex + "@ IN NS ns2\n", []string{"example.com. NS ns2.example.com.: in-zone nameserver has no address records (missing glue)"}},
+		{"TTL out of range", apex + "www 2147483648 IN A 192.0.2.2\n", []string{"www.example.com. A: TTL 2147483648 out of range"}},
+		// The apex stays the domain whatever $ORIGIN the zone ends with.
+		{"nested origin", apex + "$ORIGIN _tcp.example.com.\n_sip IN SRV 0 5 5060 ns1.example.com.\n", nil},
+		{"relative origin", apex + "$ORIGIN sub\nwww IN A 192.0.2.2\n", nil},
+		{"glue under $ORIGIN", apex + "$ORIGIN sub.example.com.\n@ IN NS ns\nns IN A 192.0.2.3\n", nil},
+		{"missing glue under $ORIGIN", apex + "$ORIGIN sub.example.com.\n@ IN NS ns\nns2 IN A 192.0.2.3\n", []string{"sub.example.com. NS ns.sub.example.com.: in-zone nameserver has no address records (missing glue)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zf := parser.ParseZone([]byte(tt.zone), "example.com")
+			if err := parser.FirstError(zf.Diagnostics); err != nil {
+				t.Fatalf("zone does not parse: %v", err)
+			}
+			err := CheckZone("example.com", zf.Data())
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("CheckZone() = %v, want no error", err)
+				}
+				return
+			}
+			var checkErr *Error
+			if !errors.As(err, &checkErr) {
+				t.Fatalf("CheckZone() = %v, want *Error", err)
+			}
+			if strings.Join(checkErr.Problems, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("CheckZone() problems = %q, want %q", checkErr.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		want string
+	}{
+		{"valid", `zone "example.com" { type master; file "example.com.db"; };`, ""},
+		{"duplicate zone", `zone "example.com" { type master; file "a"; };
+zone "example.com" { type master; file "b"; };`, "zone example.com already defined"},
+		{"primary without file", `zone "example.com" { type master; };`, "primary zones require a file"},
+		{"secondary without primaries", `zone "example.com" { type slave; file "a"; };`, "slave zones require a primaries list"},
+		{"zone outside views", `view "int" { zone "a.test" { type master; file "a"; }; };
+zone "b.test" { type master; file "b"; };`, "all zones must be in views"},
+		{"unknown key", `zone "example.com" { type slave; file "a"; masters { 192.0.2.1 key "k"; }; };`, "unknown key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "named.conf")
+			if err := os.WriteFile(path, []byte(tt.conf+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			conf, err := parser.ParseConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = CheckConfig(conf)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("CheckConfig() = %v, want no error", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("CheckConfig() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
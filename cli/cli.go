@@ -2,13 +2,16 @@ package cli
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/AfazTech/b9m/api"
 	"github.com/AfazTech/b9m/config"
 	"github.com/AfazTech/b9m/parser"
 	"github.com/AfazTech/b9m/record"
+	"github.com/AfazTech/b9m/serial"
 	"github.com/AfazTech/b9m/servicemanager"
 	"github.com/AfazTech/b9m/utils"
 	"github.com/AfazTech/b9m/zone"
@@ -16,85 +19,381 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	settingsFile string
+	profileName  string
+	flagProfile  config.Profile
+	flagLogFile  string
+	viewName     string
+	instances    *config.Instances
+)
+
+// instance resolves the profile selected with --profile.
+func instance() *config.Instance {
+	inst, err := instances.Get(profileName)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	return inst
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "b9m",
 	Short: "B9m Command Line Interface",
 	Long:  "A CLI tool for managing BIND9 configurations and operations in b9m.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		settings, err := config.Load(settingsFile)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if profileName == "" {
+			profileName = os.Getenv("B9M_PROFILE")
+		}
+		if profileName == "" || profileName == config.DefaultProfile {
+			settings.Profile = settings.Profile.Merge(flagProfile)
+		} else if p, ok := settings.Profiles[profileName]; ok {
+			settings.Profiles[profileName] = p.Merge(flagProfile)
+		}
+		if flagLogFile != "" {
+			settings.LogFile = flagLogFile
+		}
+		instances = config.NewInstances(settings)
+		logger.SetLogFile(settings.LogFileOrDefault())
+		logger.SetOutput(logger.CONSOLE_AND_FILE)
+	},
+}
+
+var soaOptions parser.SOAOptions
+
+// domainOptions collects the options of the commands adding primary
+// domains.
+func domainOptions(cmd *cobra.Command, nameservers []string) zone.DomainOptions {
+	scheme, _ := cmd.Flags().GetString("serial-scheme")
+	tmpl, _ := cmd.Flags().GetString("template")
+	return zone.DomainOptions{Nameservers: nameservers, SerialScheme: serial.Scheme(scheme), Template: tmpl, SOA: soaOptions}
 }
 
 var addDomainCmd = &cobra.Command{
-	Use:   "add-domain [domain] [ns1] [ns2]",
-	Short: "Add a new domain",
-	Args:  cobra.ExactArgs(3),
+	Use:   "add-domain [domain] [ns...]",
+	Short: "Add a new domain served by the given nameservers",
+	Args:  cobra.MinimumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		domain, ns1, ns2 := args[0], args[1], args[2]
-		if err := zone.AddDomain(domain, ns1, ns2); err != nil {
+		domain, nameservers := args[0], args[1:]
+		if err := zone.AddDomain(instance(), viewName, domain, domainOptions(cmd, nameservers)); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("Domain '%s' added successfully with nameservers '%s'.", domain, strings.Join(nameservers, "', '"))
+	},
+}
+
+var addReverseDomainCmd = &cobra.Command{
+	Use:   "add-reverse-domain [cidr] [ns...]",
+	Short: "Add the in-addr.arpa or ip6.arpa domains covering a network",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cidr := args[0]
+		result, err := zone.AddReverseDomain(instance(), viewName, cidr, domainOptions(cmd, args[1:]))
+		for _, d := range result.Domains {
+			logger.Infof("Reverse domain '%s' added successfully for '%s'.", d, cidr)
+		}
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if len(result.Delegation) > 0 {
+			fmt.Println("Add these records to the parent zone to delegate the classless reverse domain:")
+			for _, rec := range result.Delegation {
+				fmt.Println(rec)
+			}
+		}
+	},
+}
+
+var setSOACmd = &cobra.Command{
+	Use:   "set-soa [domain]",
+	Short: "Change the SOA contact and timers and the default TTL of a domain",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain := args[0]
+		if err := record.SetSOA(instance(), viewName, domain, soaOptions); err != nil {
 			logger.Fatal(err)
 		}
-		logger.Infof("Domain '%s' added successfully with nameservers '%s' and '%s'.", domain, ns1, ns2)
+		logger.Infof("SOA of domain '%s' updated successfully.", domain)
 	},
 }
 
+var (
+	secondaryKey            string
+	secondaryTransferSource string
+)
+
+// transferredZoneCmd builds the commands adding or updating secondary and
+// stub zones, which take the same arguments.
+func transferredZoneCmd(use, short, done string, apply func(*config.Instance, string, string, zone.SecondaryOptions) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " [domain] [primary...]",
+		Short: short,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			domain := args[0]
+			opts := zone.SecondaryOptions{Primaries: args[1:], Key: secondaryKey, TransferSource: secondaryTransferSource}
+			if err := apply(instance(), viewName, domain, opts); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Infof("Domain '%s' %s successfully with primaries '%s'.", domain, done, strings.Join(opts.Primaries, "', '"))
+		},
+	}
+}
+
+var (
+	addSecondaryCmd    = transferredZoneCmd("add-secondary", "Add a secondary domain transferred from the given primaries", "added as secondary", zone.AddSecondary)
+	updateSecondaryCmd = transferredZoneCmd("update-secondary", "Replace the primaries, key and transfer source of a secondary domain", "updated", zone.UpdateSecondary)
+	addStubCmd         = transferredZoneCmd("add-stub", "Add a stub domain whose NS records come from the given primaries", "added as stub", zone.AddStub)
+	updateStubCmd      = transferredZoneCmd("update-stub", "Replace the primaries, key and transfer source of a stub domain", "updated", zone.UpdateStub)
+)
+
+var forwardPolicy string
+
+func forwardZoneCmd(use, short, done string, apply func(*config.Instance, string, string, zone.ForwardOptions) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " [domain] [forwarder...]",
+		Short: short,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			domain := args[0]
+			opts := zone.ForwardOptions{Forwarders: args[1:], Forward: forwardPolicy}
+			if err := apply(instance(), viewName, domain, opts); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Infof("Domain '%s' %s successfully with forwarders '%s'.", domain, done, strings.Join(opts.Forwarders, "', '"))
+		},
+	}
+}
+
+var (
+	addForwardCmd    = forwardZoneCmd("add-forward", "Add a forward domain resolved through the given forwarders", "added as forward", zone.AddForward)
+	updateForwardCmd = forwardZoneCmd("update-forward", "Replace the forwarders and forward policy of a forward domain", "updated", zone.UpdateForward)
+)
+
 var deleteDomainCmd = &cobra.Command{
 	Use:   "delete-domain [domain]",
 	Short: "Delete a domain",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		domain := args[0]
-		if err := zone.DeleteDomain(domain); err != nil {
+		if err := zone.DeleteDomain(instance(), viewName, domain); err != nil {
 			logger.Fatal(err)
 		}
 		logger.Infof("Domain '%s' deleted successfully.", domain)
 	},
 }
 
+var setSerialSchemeCmd = &cobra.Command{
+	Use:   "set-serial-scheme [domain] [date|unix|increment]",
+	Short: "Set the SOA serial scheme of a domain",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain, scheme := args[0], args[1]
+		if err := zone.SetSerialScheme(instance(), viewName, domain, serial.Scheme(scheme)); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("Serial scheme of domain '%s' set to '%s'.", domain, scheme)
+	},
+}
+
+var setZoneOptionCmd = &cobra.Command{
+	Use:   "set-zone-option [domain] [option] [value]",
+	Short: "Set an option in the zone statement of a domain",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain, option, value := args[0], args[1], args[2]
+		if err := zone.SetZoneOption(instance(), viewName, domain, option, value); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("Option '%s' of domain '%s' set to '%s'.", option, domain, value)
+	},
+}
+
+var unsetZoneOptionCmd = &cobra.Command{
+	Use:   "unset-zone-option [domain] [option]",
+	Short: "Remove an option from the zone statement of a domain",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain, option := args[0], args[1]
+		if err := zone.SetZoneOption(instance(), viewName, domain, option, ""); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("Option '%s' removed from domain '%s'.", option, domain)
+	},
+}
+
+var syncPTR bool
+
 var addRecordCmd = &cobra.Command{
-	Use:   "add-record [domain] [name] [type] [value] [ttl]",
-	Short: "Add a new DNS record",
-	Args:  cobra.ExactArgs(5),
+	Use:   "add-record [domain] [name] [type] [value...] [ttl]",
+	Short: "Add a new DNS record; TXT records take one value per string",
+	Args:  cobra.MinimumNArgs(5),
 	Run: func(cmd *cobra.Command, args []string) {
-		domain, name, rType, value, ttlStr := args[0], args[1], args[2], args[3], args[4]
+		domain, name, rType, ttlStr := args[0], args[1], args[2], args[len(args)-1]
+		values := args[3 : len(args)-1]
+		value := values[0]
+		if len(values) > 1 {
+			if record.RecordType(rType) != record.TXT {
+				logger.Fatalf("Only TXT records accept more than one value, got %d for type '%s'.", len(values), rType)
+			}
+			value = parser.TXTData{Segments: values}.String()
+		}
 		ttl, err := strconv.Atoi(ttlStr)
 		if err != nil {
 			logger.Fatalf("Invalid TTL value '%s': %v", ttlStr, err)
 		}
-		if err := record.AddRecord(domain, record.RecordType(rType), name, value, ttl); err != nil {
+		rec, err := record.AddRecord(instance(), viewName, domain, record.RecordType(rType), name, value, ttl, syncPTR)
+		if err != nil {
 			logger.Fatal(err)
 		}
-		logger.Infof("Record added successfully: Domain: '%s', Name: '%s', Type: '%s', Value: '%s', TTL: %d.", domain, name, rType, value, ttl)
+		logger.Infof("Record added successfully: Domain: '%s', ID: '%s', Name: '%s', Type: '%s', Value: '%s', TTL: %d.", domain, rec.ID, name, rType, rec.Value, rec.TTL)
 	},
 }
 
 var deleteRecordCmd = &cobra.Command{
-	Use:   "delete-record [domain] [name] [type] [value]",
-	Short: "Delete a DNS record",
-	Args:  cobra.ExactArgs(4),
+	Use:   "delete-record [domain] ([id] | [name] [type] [value])",
+	Short: "Delete a DNS record by ID or by name, type and value",
+	Args:  oneOfArgs(2, 4),
 	Run: func(cmd *cobra.Command, args []string) {
-		domain, name, rType, value := args[0], args[1], args[2], args[3]
-		if err := record.DeleteRecord(domain, name, record.RecordType(rType), value); err != nil {
+		domain := args[0]
+		if len(args) == 2 {
+			if err := record.DeleteRecordByID(instance(), viewName, domain, args[1], syncPTR); err != nil {
+				logger.Fatal(err)
+			}
+			logger.Infof("Record deleted successfully: Domain: '%s', ID: '%s'.", domain, args[1])
+			return
+		}
+		name, rType, value := args[1], args[2], args[3]
+		if err := record.DeleteRecord(instance(), viewName, domain, name, record.RecordType(rType), value, syncPTR); err != nil {
 			logger.Fatal(err)
 		}
 		logger.Infof("Record deleted successfully: Domain: '%s', Name: '%s', Type: '%s', Value: '%s'.", domain, name, rType, value)
 	},
 }
 
+var updateRecordCmd = &cobra.Command{
+	Use:   "update-record [domain] ([id] | [name] [type] [value]) [new-value] [ttl]",
+	Short: "Update the value and TTL of a DNS record by ID or by name, type and value",
+	Args:  oneOfArgs(4, 6),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain, newValue, ttlStr := args[0], args[len(args)-2], args[len(args)-1]
+		ttl, err := strconv.Atoi(ttlStr)
+		if err != nil {
+			logger.Fatalf("Invalid TTL value '%s': %v", ttlStr, err)
+		}
+		var rec record.DNSRecord
+		if len(args) == 4 {
+			rec, err = record.UpdateRecordByID(instance(), viewName, domain, args[1], newValue, ttl, syncPTR)
+		} else {
+			name, rType, value := args[1], args[2], args[3]
+			rec, err = record.UpdateRecord(instance(), viewName, domain, name, record.RecordType(rType), value, newValue, ttl, syncPTR)
+		}
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("Record updated successfully: Domain: '%s', ID: '%s', Name: '%s', Type: '%s', Value: '%s', TTL: %d.", domain, rec.ID, rec.Name, rec.Type, rec.Value, rec.TTL)
+	},
+}
+
+var getRecordCmd = &cobra.Command{
+	Use:   "get-record [domain] [id]",
+	Short: "Get a DNS record by ID",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain, id := args[0], args[1]
+		rec, err := record.GetRecord(instance(), viewName, domain, id)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("Record: ID: '%s', Name: '%s', TTL: %d, Type: '%s', Value: '%s'.", rec.ID, rec.Name, rec.TTL, rec.Type, rec.Value)
+	},
+}
+
 var getRecordsCmd = &cobra.Command{
 	Use:   "get-records [domain]",
 	Short: "Get all records of a domain",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		domain := args[0]
-		records, err := record.GetAllRecords(domain)
+		records, err := record.GetAllRecords(instance(), viewName, domain)
 		if err != nil {
 			logger.Fatal(err)
 		}
 		logger.Infof("DNS records for domain '%s':", domain)
 		for _, record := range records {
-			logger.Infof("Record: Name: '%s', TTL: %d, Type: '%s', Value: '%s'.", record.Name, record.TTL, record.Type, record.Value)
+			logger.Infof("Record: ID: '%s', Name: '%s', TTL: %d, Type: '%s', Value: '%s'.", record.ID, record.Name, record.TTL, record.Type, record.Value)
 		}
 	},
 }
 
+var checkZoneCmd = &cobra.Command{
+	Use:   "check-zone [domain]",
+	Short: "Check the zone file of a domain and print its diagnostics",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain := args[0]
+		strict, _ := cmd.Flags().GetBool("strict")
+		diags, err := zone.CheckZone(instance(), viewName, domain, strict)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if errorCount := logDiagnostics(diags); errorCount > 0 {
+			logger.Fatalf("Zone '%s' has %d error(s).", domain, errorCount)
+		}
+		logger.Infof("Zone '%s' is valid.", domain)
+	},
+}
+
+// logDiagnostics logs diags and returns how many of them are errors.
+func logDiagnostics(diags []parser.Diagnostic) int {
+	errorCount := 0
+	for _, d := range diags {
+		if d.Severity == parser.SeverityError {
+			errorCount++
+			logger.Error(d.String())
+		} else {
+			logger.Warn(d.String())
+		}
+	}
+	return errorCount
+}
+
+var getTemplatesCmd = &cobra.Command{
+	Use:   "get-templates",
+	Short: "List the zone templates available to add-domain",
+	Run: func(cmd *cobra.Command, args []string) {
+		templates, err := zone.Templates(instance())
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Info("Zone templates:")
+		for _, t := range templates {
+			logger.Infof("Template: '%s', Source: '%s'.", t.Name, t.Source)
+		}
+	},
+}
+
+var checkTemplateCmd = &cobra.Command{
+	Use:   "check-template [name]",
+	Short: "Render a zone template for example.com and print its diagnostics",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		diags, err := zone.CheckTemplate(instance(), name)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if errorCount := logDiagnostics(diags); errorCount > 0 {
+			logger.Fatalf("Template '%s' has %d error(s).", name, errorCount)
+		}
+		logger.Infof("Template '%s' is valid.", name)
+	},
+}
+
 var startAPICmd = &cobra.Command{
 	Use:   "start-api [port] [apiKey]",
 	Short: "Start the API server",
@@ -102,7 +401,7 @@ var startAPICmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		port, apiKey := args[0], args[1]
 		logger.Infof("Starting API server on port '%s' with provided API key.", port)
-		api.StartServer(port, apiKey)
+		api.StartServer(port, apiKey, instances)
 	},
 }
 var backupCmd = &cobra.Command{
@@ -112,7 +411,7 @@ var backupCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		directory := args[0]
 		logger.Infof("all files backuped to directory: '%s'.", directory)
-		err := utils.Backup(directory)
+		err := utils.Backup(instance(), directory)
 		if err != nil {
 			logger.Fatalf("failed to backup: %v", err)
 		}
@@ -122,7 +421,7 @@ var reloadCmd = &cobra.Command{
 	Use:   "reload",
 	Short: "Reload BIND9 configuration",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := servicemanager.ReloadBind(); err != nil {
+		if err := servicemanager.ReloadBind(instance()); err != nil {
 			logger.Fatalf("Error reloading BIND9 configuration: %v", err)
 		}
 		logger.Info("BIND9 configuration reloaded successfully using 'rndc reload'.")
@@ -133,7 +432,7 @@ var restartCmd = &cobra.Command{
 	Use:   "restart",
 	Short: "Restart BIND9 service",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := servicemanager.RestartBind(); err != nil {
+		if err := servicemanager.RestartBind(instance()); err != nil {
 			logger.Fatalf("Error restarting BIND9 service: %v", err)
 		}
 		logger.Info("BIND9 service restarted successfully.")
@@ -144,7 +443,7 @@ var stopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop BIND9 service",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := servicemanager.StopBind(); err != nil {
+		if err := servicemanager.StopBind(instance()); err != nil {
 			logger.Fatalf("Error stopping BIND9 service: %v", err)
 		}
 		logger.Info("BIND9 service stopped successfully.")
@@ -155,7 +454,7 @@ var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start BIND9 service",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := servicemanager.StartBind(); err != nil {
+		if err := servicemanager.StartBind(instance()); err != nil {
 			logger.Fatalf("Error starting BIND9 service: %v", err)
 		}
 		logger.Info("BIND9 service started successfully.")
@@ -166,7 +465,7 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Get the status of BIND9 service",
 	Run: func(cmd *cobra.Command, args []string) {
-		status, err := servicemanager.StatusBind()
+		status, err := servicemanager.StatusBind(instance())
 		if err != nil {
 			logger.Fatalf("Error fetching BIND9 service status: %v", err)
 		}
@@ -178,13 +477,29 @@ var getDomainsCmd = &cobra.Command{
 	Use:   "get-domains",
 	Short: "Get all domains and their configuration files",
 	Run: func(cmd *cobra.Command, args []string) {
-		domains, err := parser.GetDomains()
+		domains, err := zone.GetDomains(instance())
 		if err != nil {
 			logger.Fatalf("Error fetching domains: %v", err)
 		}
 		logger.Info("List of domains and their associated configuration files:")
-		for domain, file := range domains {
-			logger.Infof("Domain: '%s', File: '%s'.", domain, file)
+		for _, d := range domains {
+			line := fmt.Sprintf("Domain: '%s', Type: '%s', File: '%s'", d.Name, d.Type, d.File)
+			if d.View != "" {
+				line += fmt.Sprintf(", View: '%s'", d.View)
+			}
+			if len(d.Forwarders) > 0 {
+				line += fmt.Sprintf(", Forwarders: '%s'", strings.Join(d.Forwarders, "', '"))
+				if d.Forward != "" {
+					line += fmt.Sprintf(", Forward: '%s'", d.Forward)
+				}
+			}
+			if d.Transfer != nil {
+				line += fmt.Sprintf(", Primaries: '%s', Transfer: '%s'", strings.Join(d.Primaries, "', '"), d.Transfer.State)
+				if d.Transfer.Serial != "" {
+					line += fmt.Sprintf(", Serial: '%s'", d.Transfer.Serial)
+				}
+			}
+			logger.Info(line + ".")
 		}
 	},
 }
@@ -193,8 +508,7 @@ var getConfigCmd = &cobra.Command{
 	Use:   "get-config",
 	Short: "Get all bind9 configs",
 	Run: func(cmd *cobra.Command, args []string) {
-		confFile := config.GetConfigFile()
-		configs, err := parser.ParseConfig(confFile)
+		configs, err := parser.LoadConfig(instance())
 		if err != nil {
 			logger.Fatalf("failed to parsing configuration: %v", err)
 		}
@@ -208,13 +522,83 @@ var getConfigCmd = &cobra.Command{
 	},
 }
 
+func oneOfArgs(counts ...int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		for _, n := range counts {
+			if len(args) == n {
+				return nil
+			}
+		}
+		return fmt.Errorf("accepts %v arg(s), received %d", counts, len(args))
+	}
+}
+
 func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&settingsFile, "settings", "", "b9m settings file (default "+config.DefaultSettingsFile+")")
+	flags.StringVar(&profileName, "profile", "", "settings profile of the named instance to manage (default "+config.DefaultProfile+")")
+	flags.StringVar(&flagProfile.Chroot, "chroot", "", "root directory named runs chrooted in")
+	flags.StringVar(&flagProfile.ConfigFile, "config", "", "path of named.conf")
+	flags.StringVar(&flagProfile.ZoneDir, "zone-dir", "", "directory for zone files created by b9m")
+	flags.StringVar(&flagProfile.Rndc, "rndc", "", "rndc binary (default "+config.DefaultRndc+")")
+	flags.StringVar(&flagProfile.RndcKey, "rndc-key", "", "key file passed to rndc -k")
+	flags.StringVar(&flagProfile.RndcServer, "rndc-server", "", "control channel server passed to rndc -s")
+	flags.StringVar(&flagProfile.RndcPort, "rndc-port", "", "control channel port passed to rndc -p")
+	flags.StringVar(&flagProfile.ServiceName, "service", "", "systemd service name of named (detected if empty)")
+	flags.StringVar(&flagProfile.TemplateDir, "template-dir", "", "directory of zone templates (default "+config.DefaultTemplateDir+")")
+	flags.StringVar(&flagLogFile, "log-file", "", "log file (default "+config.DefaultLogFile+")")
+	for _, cmd := range []*cobra.Command{
+		addDomainCmd, addReverseDomainCmd, setSOACmd, deleteDomainCmd, setSerialSchemeCmd, setZoneOptionCmd, unsetZoneOptionCmd,
+		addRecordCmd, deleteRecordCmd, updateRecordCmd, getRecordCmd, getRecordsCmd, checkZoneCmd,
+		addSecondaryCmd, updateSecondaryCmd, addStubCmd, updateStubCmd, addForwardCmd, updateForwardCmd,
+	} {
+		cmd.Flags().StringVar(&viewName, "view", "", "view the zone belongs to (zones outside of views if empty)")
+	}
+	for _, cmd := range []*cobra.Command{addSecondaryCmd, updateSecondaryCmd, addStubCmd, updateStubCmd} {
+		cmd.Flags().StringVar(&secondaryKey, "key", "", "TSIG key used for zone transfers")
+		cmd.Flags().StringVar(&secondaryTransferSource, "transfer-source", "", "local address zone transfers are made from")
+	}
+	for _, cmd := range []*cobra.Command{addForwardCmd, updateForwardCmd} {
+		cmd.Flags().StringVar(&forwardPolicy, "forward", "", "forward policy: only or first (named defaults to first)")
+	}
+	for _, cmd := range []*cobra.Command{addRecordCmd, deleteRecordCmd, updateRecordCmd} {
+		cmd.Flags().BoolVar(&syncPTR, "ptr", false, "also add, update or delete the PTR record of A and AAAA records")
+	}
+	for _, cmd := range []*cobra.Command{addDomainCmd, addReverseDomainCmd} {
+		cmd.Flags().String("serial-scheme", string(serial.DefaultScheme), "SOA serial scheme: date, unix or increment")
+		cmd.Flags().String("template", zone.DefaultTemplate, "zone template the zone file is created from")
+	}
+	for _, cmd := range []*cobra.Command{addDomainCmd, addReverseDomainCmd, setSOACmd} {
+		cmd.Flags().StringVar(&soaOptions.Contact, "contact", "", "SOA contact email address (default set by the template)")
+		cmd.Flags().IntVar(&soaOptions.Refresh, "refresh", 0, "SOA refresh in seconds")
+		cmd.Flags().IntVar(&soaOptions.Retry, "retry", 0, "SOA retry in seconds")
+		cmd.Flags().IntVar(&soaOptions.Expire, "expire", 0, "SOA expire in seconds")
+		cmd.Flags().IntVar(&soaOptions.Minimum, "minimum", 0, "SOA minimum (negative caching TTL) in seconds")
+		cmd.Flags().IntVar(&soaOptions.DefaultTTL, "default-ttl", 0, "default TTL ($TTL) of the zone in seconds")
+	}
+	checkZoneCmd.Flags().Bool("strict", false, "stop checking at the first error")
 	rootCmd.AddCommand(
 		addDomainCmd,
+		addReverseDomainCmd,
+		setSOACmd,
+		addSecondaryCmd,
+		updateSecondaryCmd,
+		addStubCmd,
+		updateStubCmd,
+		addForwardCmd,
+		updateForwardCmd,
 		deleteDomainCmd,
+		setSerialSchemeCmd,
+		setZoneOptionCmd,
+		unsetZoneOptionCmd,
 		addRecordCmd,
 		deleteRecordCmd,
+		updateRecordCmd,
+		getRecordCmd,
 		getRecordsCmd,
+		checkZoneCmd,
+		getTemplatesCmd,
+		checkTemplateCmd,
 		startAPICmd,
 		reloadCmd,
 		restartCmd,
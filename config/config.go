@@ -1,30 +1,219 @@
 package config
 
 import (
-	"github.com/AfazTech/logger/v2"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"sort"
+	"sync"
 )
 
-var (
-	confFile string
-	zoneDir  string
-	err      error
+const (
+	DefaultSettingsFile = "/etc/b9m/config.json"
+	DefaultLogFile      = "/var/log/b9m.log"
+	DefaultRndc         = "rndc"
+	DefaultProfile      = "default"
+	DefaultTemplateDir  = "/etc/b9m/templates"
 )
 
-func init() {
-	confFile, err = detectConfigFile()
-	if err != nil {
-		logger.Fatalf("failed to detect config file: %v", err)
+// Profile describes one named instance: where it keeps its files and how
+// to control it. Empty fields fall back to detection or defaults.
+// ConfigFile and ZoneDir are host paths; when named runs in Chroot, the
+// paths inside named.conf are taken to be relative to it. Zone templates
+// are read from TemplateDir and Templates, which maps names to template text.
+type Profile struct {
+	Chroot      string `json:"chroot,omitempty"`
+	ConfigFile  string `json:"config_file,omitempty"`
+	ZoneDir     string `json:"zone_dir,omitempty"`
+	Rndc        string `json:"rndc,omitempty"`
+	RndcKey     string `json:"rndc_key,omitempty"`
+	RndcServer  string `json:"rndc_server,omitempty"`
+	RndcPort    string `json:"rndc_port,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	TemplateDir string `json:"template_dir,omitempty"`
+
+	Templates map[string]string `json:"templates,omitempty"`
+}
+
+// Settings are the contents of the b9m settings file. The top-level
+// profile is the default one; named profiles inherit its rndc binary and
+// templates, but not the fields identifying its named instance.
+type Settings struct {
+	Profile
+	LogFile  string             `json:"log_file,omitempty"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// Instance is a resolved profile. Every operation on named takes the
+// instance it applies to.
+type Instance struct {
+	Name string `json:"name"`
+	Profile
+}
+
+var profileEnv = map[string]func(p *Profile) *string{
+	"B9M_CHROOT":       func(p *Profile) *string { return &p.Chroot },
+	"B9M_CONFIG_FILE":  func(p *Profile) *string { return &p.ConfigFile },
+	"B9M_ZONE_DIR":     func(p *Profile) *string { return &p.ZoneDir },
+	"B9M_RNDC":         func(p *Profile) *string { return &p.Rndc },
+	"B9M_RNDC_KEY":     func(p *Profile) *string { return &p.RndcKey },
+	"B9M_RNDC_SERVER":  func(p *Profile) *string { return &p.RndcServer },
+	"B9M_RNDC_PORT":    func(p *Profile) *string { return &p.RndcPort },
+	"B9M_SERVICE_NAME": func(p *Profile) *string { return &p.ServiceName },
+	"B9M_TEMPLATE_DIR": func(p *Profile) *string { return &p.TemplateDir },
+}
+
+// Load reads the settings file at path, or at $B9M_SETTINGS or the default
+// location when path is empty, and applies B9M_* environment variables to
+// the default profile. Only an explicitly given file has to exist.
+func Load(path string) (Settings, error) {
+	var s Settings
+	explicit := path != ""
+	if path == "" {
+		path = os.Getenv("B9M_SETTINGS")
+		explicit = path != ""
 	}
-	zoneDir, err = detectZoneDir()
-	if err != nil {
-		logger.Fatalf("failed to detect zone directory: %v", err)
+	if path == "" {
+		path = DefaultSettingsFile
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &s); err != nil {
+			return s, fmt.Errorf("failed to parse settings file %s: %w", path, err)
+		}
+	case !explicit && errors.Is(err, fs.ErrNotExist):
+	default:
+		return s, fmt.Errorf("failed to read settings file %s: %w", path, err)
+	}
+	for name, field := range profileEnv {
+		if v := os.Getenv(name); v != "" {
+			*field(&s.Profile) = v
+		}
+	}
+	if v := os.Getenv("B9M_LOG_FILE"); v != "" {
+		s.LogFile = v
+	}
+	return s, nil
+}
+
+// Merge returns p with every non-empty field of o, and every template of o,
+// taking precedence.
+func (p Profile) Merge(o Profile) Profile {
+	for _, field := range profileEnv {
+		if v := *field(&o); v != "" {
+			*field(&p) = v
+		}
+	}
+	if len(o.Templates) > 0 {
+		templates := maps.Clone(p.Templates)
+		if templates == nil {
+			templates = map[string]string{}
+		}
+		maps.Copy(templates, o.Templates)
+		p.Templates = templates
+	}
+	return p
+}
+
+// shared returns p without the fields identifying its named instance:
+// chroot, files, control channel and service.
+func (p Profile) shared() Profile {
+	p.Chroot, p.ConfigFile, p.ZoneDir = "", "", ""
+	p.RndcKey, p.RndcServer, p.RndcPort, p.ServiceName = "", "", "", ""
+	return p
+}
+
+func (s Settings) ProfileNames() []string {
+	names := []string{DefaultProfile}
+	for name := range s.Profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Instance resolves the profile called name, the default one if name is
+// empty. The named.conf path, zone directory and service of the default
+// profile are detected when not set; named profiles must set them.
+func (s Settings) Instance(name string) (*Instance, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p := s.Profile
+	if name != DefaultProfile {
+		named, ok := s.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		p = p.shared().Merge(named)
+		if p.ServiceName == "" {
+			return nil, fmt.Errorf("profile %q does not set service_name", name)
+		}
+	}
+	var err error
+	if p.ConfigFile == "" {
+		if name != DefaultProfile {
+			return nil, fmt.Errorf("profile %q does not set config_file", name)
+		}
+		if p.ConfigFile, err = detectConfigFile(p.Chroot); err != nil {
+			return nil, fmt.Errorf("%w: set config_file in %s, B9M_CONFIG_FILE or --config", err, DefaultSettingsFile)
+		}
+	}
+	if p.ZoneDir == "" {
+		if name != DefaultProfile {
+			return nil, fmt.Errorf("profile %q does not set zone_dir", name)
+		}
+		if p.ZoneDir, err = detectZoneDir(p.Chroot); err != nil {
+			return nil, fmt.Errorf("%w: set zone_dir in %s, B9M_ZONE_DIR or --zone-dir", err, DefaultSettingsFile)
+		}
+	}
+	if p.Rndc == "" {
+		p.Rndc = DefaultRndc
 	}
+	if p.TemplateDir == "" {
+		p.TemplateDir = DefaultTemplateDir
+	}
+	return &Instance{Name: name, Profile: p}, nil
+}
+
+// Instances keeps the instances resolved so far, so that profiles are only
+// resolved once per process.
+type Instances struct {
+	settings Settings
+	mu       sync.Mutex
+	resolved map[string]*Instance
+}
+
+func NewInstances(s Settings) *Instances {
+	return &Instances{settings: s, resolved: map[string]*Instance{}}
 }
 
-func GetConfigFile() string {
-	return confFile
+func (is *Instances) Get(name string) (*Instance, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if inst, ok := is.resolved[name]; ok {
+		return inst, nil
+	}
+	inst, err := is.settings.Instance(name)
+	if err != nil {
+		return nil, err
+	}
+	is.resolved[name] = inst
+	return inst, nil
 }
 
-func GetZoneDir() string {
-	return zoneDir
+func (s Settings) LogFileOrDefault() string {
+	if s.LogFile == "" {
+		return DefaultLogFile
+	}
+	return s.LogFile
 }
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// clearEnv unsets the B9M_* variables for the duration of the test.
+func clearEnv(t *testing.T) {
+	for name := range profileEnv {
+		t.Setenv(name, "")
+	}
+	t.Setenv("B9M_SETTINGS", "")
+	t.Setenv("B9M_LOG_FILE", "")
+}
+
+func writeSettings(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	clearEnv(t)
+	path := writeSettings(t, `{"config_file": "/etc/named.conf", "zone_dir": "/var/named", "rndc": "/usr/sbin/rndc", "log_file": "/var/log/b9m.log"}`)
+	t.Setenv("B9M_ZONE_DIR", "/srv/zones")
+	t.Setenv("B9M_LOG_FILE", "/tmp/b9m.log")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Profile{ConfigFile: "/etc/named.conf", ZoneDir: "/srv/zones", Rndc: "/usr/sbin/rndc"}
+	if !reflect.DeepEqual(s.Profile, want) {
+		t.Errorf("profile = %+v, want %+v", s.Profile, want)
+	}
+	if s.LogFile != "/tmp/b9m.log" {
+		t.Errorf("log file = %q, want the one from B9M_LOG_FILE", s.LogFile)
+	}
+
+	t.Setenv("B9M_SETTINGS", path)
+	if s, err := Load(""); err != nil || s.ConfigFile != "/etc/named.conf" {
+		t.Errorf("Load(\"\") with B9M_SETTINGS = %+v, %v", s.Profile, err)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	clearEnv(t)
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if _, err := Load(missing); err == nil {
+		t.Error("Load() of a missing explicit file succeeded")
+	}
+	t.Setenv("B9M_SETTINGS", missing)
+	if _, err := Load(""); err == nil {
+		t.Error("Load() of a missing file named by B9M_SETTINGS succeeded")
+	}
+	if _, err := Load(writeSettings(t, "{")); err == nil {
+		t.Error("Load() of an invalid file succeeded")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := Profile{ConfigFile: "/etc/named.conf", Rndc: "rndc", Templates: map[string]string{"web": "a", "mail": "b"}}
+	got := base.Merge(Profile{Rndc: "/opt/rndc", Templates: map[string]string{"web": "c"}})
+	want := Profile{ConfigFile: "/etc/named.conf", Rndc: "/opt/rndc", Templates: map[string]string{"web": "c", "mail": "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %+v, want %+v", got, want)
+	}
+	if base.Templates["web"] != "a" {
+		t.Error("Merge() modified the templates of the receiver")
+	}
+}
+
+func TestDefaultInstance(t *testing.T) {
+	s := Settings{Profile: Profile{ConfigFile: "/etc/named.conf", ZoneDir: "/var/named"}}
+	inst, err := s.Instance("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Name != DefaultProfile || inst.ConfigFile != "/etc/named.conf" || inst.ZoneDir != "/var/named" {
+		t.Errorf("Instance(\"\") = %+v", inst)
+	}
+	if inst.Rndc != DefaultRndc || inst.TemplateDir != DefaultTemplateDir {
+		t.Errorf("Instance(\"\") has rndc %q and template dir %q, want the defaults", inst.Rndc, inst.TemplateDir)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	s := Settings{
+		Profile: Profile{
+			ConfigFile: "/etc/bind/named.conf", ZoneDir: "/var/lib/bind", Chroot: "/srv/chroot",
+			Rndc: "/opt/rndc", RndcKey: "/etc/bind/rndc.key", ServiceName: "named",
+			Templates: map[string]string{"web": "/etc/b9m/web.zone"},
+		},
+		Profiles: map[string]Profile{
+			"lab":        {ConfigFile: "/etc/lab/named.conf", ZoneDir: "/var/lab", ServiceName: "named-lab"},
+			"no-service": {ConfigFile: "/etc/lab/named.conf", ZoneDir: "/var/lab"},
+			"no-config":  {ZoneDir: "/var/lab", ServiceName: "named-lab"},
+			"no-zones":   {ConfigFile: "/etc/lab/named.conf", ServiceName: "named-lab"},
+		},
+	}
+	inst, err := s.Instance("lab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Profile{
+		ConfigFile: "/etc/lab/named.conf", ZoneDir: "/var/lab", ServiceName: "named-lab",
+		Rndc: "/opt/rndc", TemplateDir: DefaultTemplateDir,
+		Templates: map[string]string{"web": "/etc/b9m/web.zone"},
+	}
+	if inst.Name != "lab" || !reflect.DeepEqual(inst.Profile, want) {
+		t.Errorf("Instance(\"lab\") = %s %+v, want lab %+v", inst.Name, inst.Profile, want)
+	}
+
+	for _, name := range []string{"no-service", "no-config", "no-zones", "unknown"} {
+		if _, err := s.Instance(name); err == nil {
+			t.Errorf("Instance(%q) succeeded", name)
+		}
+	}
+
+	names := s.ProfileNames()
+	wantNames := []string{DefaultProfile, "lab", "no-config", "no-service", "no-zones"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("ProfileNames() = %q, want %q", names, wantNames)
+	}
+}
+
+func TestInstancesCache(t *testing.T) {
+	is := NewInstances(Settings{Profile: Profile{ConfigFile: "/etc/named.conf", ZoneDir: "/var/named"}})
+	a, err := is.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := is.Get(DefaultProfile); err != nil || a != b {
+		t.Errorf("Get(%q) = %p, %v, want the instance resolved for \"\" (%p)", DefaultProfile, b, err, a)
+	}
+}
+
+func TestDetectInChroot(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"etc/bind", "var/lib/bind"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc/bind/named.conf"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	inst, err := Settings{Profile: Profile{Chroot: root}}.Instance("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, "etc/bind/named.conf"); inst.ConfigFile != want {
+		t.Errorf("config file = %s, want %s", inst.ConfigFile, want)
+	}
+	if want := filepath.Join(root, "var/lib/bind"); inst.ZoneDir != want {
+		t.Errorf("zone directory = %s, want %s", inst.ZoneDir, want)
+	}
+}
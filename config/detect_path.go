@@ -3,9 +3,10 @@ package config
 import (
 	"errors"
 	"os"
+	"path/filepath"
 )
 
-func detectConfigFile() (string, error) {
+func detectConfigFile(chroot string) (string, error) {
 	configPaths := []string{
 		"/etc/named.conf",
 		"/etc/bind/named.conf",
@@ -13,6 +14,7 @@ func detectConfigFile() (string, error) {
 	}
 
 	for _, path := range configPaths {
+		path = filepath.Join("/", chroot, path)
 		if _, err := os.Stat(path); err == nil {
 			return path, nil
 		}
@@ -21,7 +23,7 @@ func detectConfigFile() (string, error) {
 	return "", errors.New("config file not found")
 }
 
-func detectZoneDir() (string, error) {
+func detectZoneDir(chroot string) (string, error) {
 	zoneDirs := []string{
 		"/var/named",
 		"/var/lib/bind",
@@ -30,6 +32,7 @@ func detectZoneDir() (string, error) {
 	}
 
 	for _, dir := range zoneDirs {
+		dir = filepath.Join("/", chroot, dir)
 		if stat, err := os.Stat(dir); err == nil && stat.IsDir() {
 			return dir, nil
 		}
@@ -3,13 +3,13 @@ module github.com/AfazTech/b9m
 go 1.23.3
 
 require (
+	github.com/AfazTech/logger/v2 v2.0.0
 	github.com/gin-gonic/gin v1.10.0
 	github.com/miekg/dns v1.1.62
 	github.com/spf13/cobra v1.8.1
 )
 
 require (
-	github.com/AfazTech/logger/v2 v2.0.0 // indirect
 	github.com/bytedance/sonic v1.11.6 // indirect
 	github.com/bytedance/sonic/loader v0.1.1 // indirect
 	github.com/cloudwego/base64x v0.1.4 // indirect
@@ -0,0 +1,127 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Locks combine an in-process mutex with an advisory flock on a file under
+// the lock directory, so they serialize goroutines of one b9m process as
+// well as separate b9m processes. The config lock must always be taken
+// before any zone lock. Locks of different profiles are independent.
+
+var (
+	dir     = "/run/b9m"
+	mu      sync.Mutex
+	mutexes = map[string]*sync.Mutex{}
+)
+
+type Lock struct {
+	name string
+	mu   *sync.Mutex
+	file *os.File
+}
+
+func SetDir(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	dir = path
+}
+
+func lockDir() (string, error) {
+	mu.Lock()
+	d := dir
+	mu.Unlock()
+	if err := os.MkdirAll(d, 0755); err != nil {
+		return "", fmt.Errorf("failed to create lock directory %s: %w", d, err)
+	}
+	return d, nil
+}
+
+func mutexFor(name string) *sync.Mutex {
+	mu.Lock()
+	defer mu.Unlock()
+	m, ok := mutexes[name]
+	if !ok {
+		m = &sync.Mutex{}
+		mutexes[name] = m
+	}
+	return m
+}
+
+func acquire(name string) (*Lock, error) {
+	m := mutexFor(name)
+	m.Lock()
+	d, err := lockDir()
+	if err != nil {
+		m.Unlock()
+		return nil, err
+	}
+	path := filepath.Join(d, name+".lock")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		m.Unlock()
+		return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		m.Unlock()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return &Lock{name: name, mu: m, file: f}, nil
+}
+
+// scoped prefixes name with the profile, leaving the default profile with
+// the plain lock names.
+func scoped(profile, name string) (string, error) {
+	if profile == "" || profile == "default" {
+		return name, nil
+	}
+	if strings.ContainsAny(profile, `/\`) {
+		return "", fmt.Errorf("invalid profile name for lock: %q", profile)
+	}
+	return profile + "-" + name, nil
+}
+
+func Config(profile string) (*Lock, error) {
+	name, err := scoped(profile, "config")
+	if err != nil {
+		return nil, err
+	}
+	return acquire(name)
+}
+
+func Zone(profile, view, domain string) (*Lock, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if domain == "" || strings.ContainsAny(domain, `/\`) {
+		return nil, fmt.Errorf("invalid zone name for lock: %q", domain)
+	}
+	if strings.ContainsAny(view, `/\`) {
+		return nil, fmt.Errorf("invalid view name for lock: %q", view)
+	}
+	name := "zone-" + domain
+	if view != "" {
+		name = "view-" + view + "-" + name
+	}
+	name, err := scoped(profile, name)
+	if err != nil {
+		return nil, err
+	}
+	return acquire(name)
+}
+
+func (l *Lock) Unlock() {
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+	l.mu.Unlock()
+}
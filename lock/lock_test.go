@@ -0,0 +1,123 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	tmp, err := os.MkdirTemp("", "b9m-lock")
+	if err != nil {
+		panic(err)
+	}
+	SetDir(tmp)
+	code := m.Run()
+	os.RemoveAll(tmp)
+	os.Exit(code)
+}
+
+// acquired reports whether take returns within a short wait.
+func acquired(take func() (*Lock, error)) (*Lock, bool) {
+	done := make(chan *Lock, 1)
+	go func() {
+		l, err := take()
+		if err != nil {
+			panic(err)
+		}
+		done <- l
+	}()
+	select {
+	case l := <-done:
+		return l, true
+	case <-time.After(100 * time.Millisecond):
+		l := <-done
+		return l, false
+	}
+}
+
+func TestExclusion(t *testing.T) {
+	first, err := Zone("", "", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another process opens the lock file on its own and must not get it.
+	f, err := os.Open(filepath.Join(dir, "zone-example.com.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != syscall.EWOULDBLOCK {
+		t.Errorf("flock of a held lock file = %v, want %v", err, syscall.EWOULDBLOCK)
+	}
+	f.Close()
+
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		close(released)
+		first.Unlock()
+	}()
+	second, err := Zone("", "", "Example.COM.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-released:
+	default:
+		t.Error("second lock of example.com taken while the first was held")
+	}
+	second.Unlock()
+}
+
+func TestIndependentLocks(t *testing.T) {
+	held, err := Zone("", "", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Unlock()
+	for name, take := range map[string]func() (*Lock, error){
+		"other zone":    func() (*Lock, error) { return Zone("", "", "example.net") },
+		"other view":    func() (*Lock, error) { return Zone("", "int", "example.com") },
+		"other profile": func() (*Lock, error) { return Zone("lab", "", "example.com") },
+		"config":        func() (*Lock, error) { return Config("") },
+	} {
+		l, ok := acquired(take)
+		if !ok {
+			t.Errorf("%s: blocked by the lock of example.com", name)
+		}
+		l.Unlock()
+	}
+}
+
+func TestLockDirError(t *testing.T) {
+	mu.Lock()
+	old := dir
+	mu.Unlock()
+	defer SetDir(old)
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	SetDir(filepath.Join(file, "locks"))
+	if l, err := Config(""); err == nil {
+		l.Unlock()
+		t.Fatal("Config() succeeded with an unusable lock directory")
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	for _, take := range []func() (*Lock, error){
+		func() (*Lock, error) { return Zone("", "", "../etc") },
+		func() (*Lock, error) { return Zone("", "a/b", "example.com") },
+		func() (*Lock, error) { return Zone("", "", "") },
+		func() (*Lock, error) { return Config("../x") },
+	} {
+		if l, err := take(); err == nil {
+			l.Unlock()
+			t.Error("lock with an invalid name succeeded")
+		}
+	}
+}
@@ -1,128 +1,526 @@
 package parser
 
 import (
-	"bufio"
+	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
-
-	"github.com/AfazTech/logger/v2"
 )
 
-var confcommentRegex = regexp.MustCompile(`^\s*(//.*|#.*)?$`)
-var keyValueRegex = regexp.MustCompile(`^\s*(\S+)\s+(.+?)\s*(;)?$`)
-var blockStartRegex = regexp.MustCompile(`^\s*(\S.*?)\s*{\s*$`)
-var inlineBlockRegex = regexp.MustCompile(`^\s*(\S+)\s*{\s*(.*?)\s*}\s*(;)?$`)
-var includeRegex = regexp.MustCompile(`^\s*include\s+["']?([^"']+)["']?\s*(;)?$`)
-
-func parseBlock(scanner *bufio.Scanner) map[string]interface{} {
-	block := make(map[string]interface{})
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if confcommentRegex.MatchString(line) {
-			continue
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"-"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+type ParseError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Value is either a word (quoted or bare) or a { } block of statements.
+type Value struct {
+	Word    string       `json:"word,omitempty"`
+	Quoted  bool         `json:"quoted,omitempty"`
+	IsBlock bool         `json:"-"`
+	Block   []*Statement `json:"block,omitempty"`
+	Pos     Position     `json:"-"`
+	End     int          `json:"-"`
+}
+
+// Statement is a single named.conf statement terminated by a semicolon.
+// Start and End are byte offsets into the source of File, End pointing just
+// past the semicolon.
+type Statement struct {
+	Name     string      `json:"name"`
+	Values   []*Value    `json:"values,omitempty"`
+	Included []*ConfFile `json:"included,omitempty"`
+	Pos      Position    `json:"position"`
+	Start    int         `json:"-"`
+	End      int         `json:"-"`
+	File     *ConfFile   `json:"-"`
+	Parent   *Statement  `json:"-"`
+}
+
+type ConfFile struct {
+	Path       string       `json:"path"`
+	Src        []byte       `json:"-"`
+	Statements []*Statement `json:"statements"`
+}
+
+type Config struct {
+	Root  *ConfFile   `json:"root"`
+	Files []*ConfFile `json:"-"`
+
+	path    string
+	chroot  string
+	workDir string
+	pending map[string][]byte
+}
+
+func (s *Statement) Args() []string {
+	var args []string
+	for _, v := range s.Values {
+		if v.IsBlock {
+			break
 		}
-		if line == "}" || line == "};" {
-			return block
+		args = append(args, v.Word)
+	}
+	return args
+}
+
+func (s *Statement) Arg(i int) string {
+	args := s.Args()
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+func (s *Statement) BlockValue() *Value {
+	for _, v := range s.Values {
+		if v.IsBlock {
+			return v
 		}
-		if blockStartMatches := blockStartRegex.FindStringSubmatch(line); blockStartMatches != nil {
-			block[blockStartMatches[1]] = parseBlock(scanner)
-			continue
+	}
+	return nil
+}
+
+func (s *Statement) Block() []*Statement {
+	if v := s.BlockValue(); v != nil {
+		return v.Block
+	}
+	return nil
+}
+
+func (s *Statement) Find(name string) *Statement {
+	for _, child := range expandIncludes(s.Block()) {
+		if child.Name == name {
+			return child
 		}
-		if inlineMatches := inlineBlockRegex.FindStringSubmatch(line); inlineMatches != nil {
-			key := inlineMatches[1]
-			listContent := inlineMatches[2]
-			parts := strings.Split(listContent, ";")
-			var values []string
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if part != "" {
-					values = append(values, part)
-				}
-			}
-			if len(values) == 1 {
-				block[key] = values[0]
-			} else {
-				block[key] = values
+	}
+	return nil
+}
+
+func (s *Statement) FindAll(name string) []*Statement {
+	var found []*Statement
+	for _, child := range expandIncludes(s.Block()) {
+		if child.Name == name {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+// Elements returns the entries of a list block such as allow-transfer or an
+// acl, each as its words joined by a space.
+func (s *Statement) Elements() []string {
+	var elements []string
+	for _, child := range expandIncludes(s.Block()) {
+		words := append([]string{child.Name}, child.Args()...)
+		elements = append(elements, strings.Join(words, " "))
+	}
+	return elements
+}
+
+// Text returns the statement exactly as written in its source file.
+func (s *Statement) Text() string {
+	return string(s.File.Src[s.Start:s.End])
+}
+
+func expandIncludes(stmts []*Statement) []*Statement {
+	var out []*Statement
+	for _, s := range stmts {
+		if s.Name == "include" {
+			for _, inc := range s.Included {
+				out = append(out, expandIncludes(inc.Statements)...)
 			}
 			continue
 		}
-		if kvMatches := keyValueRegex.FindStringSubmatch(line); kvMatches != nil {
-			block[kvMatches[1]] = strings.Trim(kvMatches[2], "\"")
+		out = append(out, s)
+	}
+	return out
+}
+
+// Statements returns the top-level statements with include statements
+// replaced by the statements of the files they include.
+func (c *Config) Statements() []*Statement {
+	return expandIncludes(c.Root.Statements)
+}
+
+func (c *Config) FindAll(name string) []*Statement {
+	var found []*Statement
+	for _, s := range c.Statements() {
+		if s.Name == name {
+			found = append(found, s)
 		}
 	}
-	return block
+	return found
+}
+
+func (c *Config) Options() *Statement {
+	if options := c.FindAll("options"); len(options) > 0 {
+		return options[0]
+	}
+	return nil
+}
+
+type Zone struct {
+	Name       string
+	Class      string
+	View       string
+	Type       string
+	File       string
+	Primaries  []string
+	Forwarders []string
+	Forward    string
+	Stmt       *Statement
+}
+
+func (z *Zone) IsPrimary() bool {
+	return z.Type == "master" || z.Type == "primary"
+}
+
+func (z *Zone) IsSecondary() bool {
+	return z.Type == "slave" || z.Type == "secondary"
+}
+
+func (z *Zone) IsStub() bool {
+	return z.Type == "stub"
+}
+
+func (z *Zone) IsForward() bool {
+	return z.Type == "forward"
+}
+
+type View struct {
+	Name  string
+	Class string
+	Zones []*Zone
+	Stmt  *Statement
+}
+
+type ACL struct {
+	Name     string
+	Elements []string
+	Stmt     *Statement
+}
+
+type Key struct {
+	Name      string
+	Algorithm string
+	Secret    string
+	Stmt      *Statement
+}
+
+type PrimariesList struct {
+	Name      string
+	Addresses []string
+	Stmt      *Statement
+}
+
+type Control struct {
+	Type    string
+	Address string
+	Port    string
+	Allow   []string
+	Keys    []string
+	Stmt    *Statement
 }
 
-func mergeConfig(dst, src map[string]interface{}) {
-	for k, v := range src {
-		if existing, ok := dst[k]; ok {
-			if dstMap, ok1 := existing.(map[string]interface{}); ok1 {
-				if srcMap, ok2 := v.(map[string]interface{}); ok2 {
-					mergeConfig(dstMap, srcMap)
-					continue
+func newZone(s *Statement, view string) *Zone {
+	z := &Zone{Name: strings.TrimSuffix(s.Arg(0), "."), Class: s.Arg(1), View: view, Stmt: s}
+	if t := s.Find("type"); t != nil {
+		z.Type = t.Arg(0)
+	}
+	if f := s.Find("file"); f != nil {
+		z.File = f.Arg(0)
+	}
+	for _, name := range []string{"primaries", "masters"} {
+		if p := s.Find(name); p != nil {
+			z.Primaries = p.Elements()
+			break
+		}
+	}
+	if f := s.Find("forwarders"); f != nil {
+		z.Forwarders = f.Elements()
+	}
+	if f := s.Find("forward"); f != nil {
+		z.Forward = f.Arg(0)
+	}
+	return z
+}
+
+func (c *Config) Views() []*View {
+	var views []*View
+	for _, s := range c.FindAll("view") {
+		v := &View{Name: s.Arg(0), Class: s.Arg(1), Stmt: s}
+		for _, zs := range s.FindAll("zone") {
+			v.Zones = append(v.Zones, newZone(zs, v.Name))
+		}
+		views = append(views, v)
+	}
+	return views
+}
+
+// Zones returns every zone in the configuration, including zones declared
+// inside views.
+func (c *Config) Zones() []*Zone {
+	var zones []*Zone
+	for _, s := range c.FindAll("zone") {
+		zones = append(zones, newZone(s, ""))
+	}
+	for _, v := range c.Views() {
+		zones = append(zones, v.Zones...)
+	}
+	return zones
+}
+
+func (c *Config) ACLs() []*ACL {
+	var acls []*ACL
+	for _, s := range c.FindAll("acl") {
+		acls = append(acls, &ACL{Name: s.Arg(0), Elements: s.Elements(), Stmt: s})
+	}
+	return acls
+}
+
+func (c *Config) Keys() []*Key {
+	var keys []*Key
+	for _, s := range c.FindAll("key") {
+		k := &Key{Name: s.Arg(0), Stmt: s}
+		if a := s.Find("algorithm"); a != nil {
+			k.Algorithm = a.Arg(0)
+		}
+		if sec := s.Find("secret"); sec != nil {
+			k.Secret = sec.Arg(0)
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (c *Config) PrimariesLists() []*PrimariesList {
+	var lists []*PrimariesList
+	for _, name := range []string{"primaries", "masters"} {
+		for _, s := range c.FindAll(name) {
+			lists = append(lists, &PrimariesList{Name: s.Arg(0), Addresses: s.Elements(), Stmt: s})
+		}
+	}
+	return lists
+}
+
+func (c *Config) Controls() []*Control {
+	var controls []*Control
+	for _, s := range c.FindAll("controls") {
+		for _, ch := range expandIncludes(s.Block()) {
+			ctl := &Control{Type: ch.Name, Stmt: ch}
+			expect := "address"
+			for _, v := range ch.Values {
+				switch {
+				case v.IsBlock && expect == "allow":
+					ctl.Allow = blockWords(v.Block)
+				case v.IsBlock && expect == "keys":
+					ctl.Keys = blockWords(v.Block)
+				case v.IsBlock:
+				case expect == "address":
+					ctl.Address = v.Word
+					expect = ""
+				case expect == "port":
+					ctl.Port = v.Word
+					expect = ""
+				default:
+					expect = v.Word
 				}
 			}
+			controls = append(controls, ctl)
 		}
-		dst[k] = v
 	}
+	return controls
+}
+
+func blockWords(stmts []*Statement) []string {
+	var words []string
+	for _, s := range stmts {
+		words = append(words, strings.Join(append([]string{s.Name}, s.Args()...), " "))
+	}
+	return words
+}
+
+func ParseConfig(file string) (*Config, error) {
+	return ParseConfigIn(file, "")
+}
+
+// ParseConfigIn parses the named.conf at file, a host path, of a named
+// running in chroot. Absolute paths inside the configuration are taken to be
+// relative to chroot.
+func ParseConfigIn(file, chroot string) (*Config, error) {
+	c := &Config{path: file, chroot: chroot, pending: map[string][]byte{}}
+	if err := c.parse(); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
-func ParseConfig(file string) (map[string]interface{}, error) {
-	config := make(map[string]interface{})
-	f, err := os.Open(file)
+func (c *Config) parse() error {
+	c.Files = nil
+	root, err := c.load(c.path, Position{}, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	c.Root = root
+	return nil
+}
+
+func (c *Config) load(path string, from Position, seen map[string]bool) (*ConfFile, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if seen[path] {
+		return nil, &ParseError{Pos: from, Msg: fmt.Sprintf("include loop on %s", path)}
+	}
+	seen[path] = true
+	defer delete(seen, path)
+
+	src, ok := c.pending[path]
+	var err error
+	if !ok {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		if from.File == "" {
+			return nil, err
+		}
+		return nil, &ParseError{Pos: from, Msg: err.Error()}
+	}
+	f := &ConfFile{Path: path, Src: src}
+	c.Files = append(c.Files, f)
+	p := &confParser{lex: newConfLexer(path, src), file: f}
+	f.Statements, err = p.parseStatements(nil, false)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	scanner := bufio.NewScanner(f)
-	baseDir := filepath.Dir(file)
+	if err := c.resolveIncludes(f.Statements, seen); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if confcommentRegex.MatchString(line) {
+func (c *Config) resolveIncludes(stmts []*Statement, seen map[string]bool) error {
+	for _, s := range stmts {
+		if s.Name != "include" {
+			for _, v := range s.Values {
+				if v.IsBlock {
+					if err := c.resolveIncludes(v.Block, seen); err != nil {
+						return err
+					}
+				}
+			}
 			continue
 		}
-		if includeMatches := includeRegex.FindStringSubmatch(line); includeMatches != nil {
-			includePath := includeMatches[1]
-			if !filepath.IsAbs(includePath) {
-				includePath = filepath.Join(baseDir, includePath)
-			}
-			incConfig, err := ParseConfig(includePath)
-			if err == nil {
-				mergeConfig(config, incConfig)
+		pattern := s.Arg(0)
+		if pattern == "" {
+			return &ParseError{Pos: s.Pos, Msg: "include requires a file name"}
+		}
+		if filepath.IsAbs(pattern) {
+			pattern = c.HostPath(pattern)
+		} else {
+			pattern = filepath.Join(filepath.Dir(s.File.Path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return &ParseError{Pos: s.Pos, Msg: fmt.Sprintf("invalid include pattern %s: %v", pattern, err)}
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return &ParseError{Pos: s.Pos, Msg: fmt.Sprintf("include file not found: %s", pattern)}
+		}
+		for _, match := range matches {
+			inc, err := c.load(match, s.Pos, seen)
+			if err != nil {
+				return err
 			}
-			continue
+			s.Included = append(s.Included, inc)
 		}
-		if blockStartMatches := blockStartRegex.FindStringSubmatch(line); blockStartMatches != nil {
-			config[blockStartMatches[1]] = parseBlock(scanner)
-			continue
+	}
+	return nil
+}
+
+type confParser struct {
+	lex  *confLexer
+	file *ConfFile
+}
+
+func (p *confParser) parseStatements(parent *Statement, inBlock bool) ([]*Statement, error) {
+	var stmts []*Statement
+	for {
+		tok, err := p.lex.next()
+		if err != nil {
+			return nil, err
 		}
-		if inlineMatches := inlineBlockRegex.FindStringSubmatch(line); inlineMatches != nil {
-			key := inlineMatches[1]
-			listContent := inlineMatches[2]
-			parts := strings.Split(listContent, ";")
-			var values []string
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if part != "" {
-					values = append(values, part)
-				}
+		switch tok.kind {
+		case tokEOF:
+			if inBlock {
+				return nil, &ParseError{Pos: tok.pos, Msg: "unexpected end of file, missing '}'"}
 			}
-			if len(values) == 1 {
-				config[key] = values[0]
-			} else {
-				config[key] = values
+			return stmts, nil
+		case tokClose:
+			if !inBlock {
+				return nil, &ParseError{Pos: tok.pos, Msg: "unexpected '}'"}
 			}
+			p.lex.unread(tok)
+			return stmts, nil
+		case tokSemicolon:
 			continue
+		case tokOpen:
+			return nil, &ParseError{Pos: tok.pos, Msg: "unexpected '{', expected statement name"}
 		}
-		if kvMatches := keyValueRegex.FindStringSubmatch(line); kvMatches != nil {
-			config[kvMatches[1]] = strings.Trim(kvMatches[2], "\"")
+		s := &Statement{Name: tok.text, Pos: tok.pos, Start: tok.pos.Offset, File: p.file, Parent: parent}
+		if err := p.parseValues(s); err != nil {
+			return nil, err
 		}
+		stmts = append(stmts, s)
 	}
-	if err := scanner.Err(); err != nil {
-		logger.Fatal(err)
+}
+
+func (p *confParser) parseValues(s *Statement) error {
+	for {
+		tok, err := p.lex.next()
+		if err != nil {
+			return err
+		}
+		switch tok.kind {
+		case tokSemicolon:
+			s.End = tok.pos.Offset + 1
+			return nil
+		case tokWord, tokString:
+			s.Values = append(s.Values, &Value{Word: tok.text, Quoted: tok.kind == tokString, Pos: tok.pos})
+		case tokOpen:
+			v := &Value{IsBlock: true, Pos: tok.pos}
+			v.Block, err = p.parseStatements(s, true)
+			if err != nil {
+				return err
+			}
+			closing, err := p.lex.next()
+			if err != nil {
+				return err
+			}
+			v.End = closing.pos.Offset + 1
+			if v.Block == nil {
+				v.Block = []*Statement{}
+			}
+			s.Values = append(s.Values, v)
+		case tokClose:
+			return &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("missing ';' after %s statement", s.Name)}
+		case tokEOF:
+			return &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected end of file in %s statement, missing ';'", s.Name)}
+		}
 	}
-	return config, nil
 }
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Edits splice text into the source of the file holding the affected
+// statement and reparse the whole configuration, so statements obtained
+// before an edit must not be reused after it.
+
+func (c *Config) splice(f *ConfFile, start, end int, text string) error {
+	src := make([]byte, 0, len(f.Src)-(end-start)+len(text))
+	src = append(src, f.Src[:start]...)
+	src = append(src, text...)
+	src = append(src, f.Src[end:]...)
+	old, hadOld := c.pending[f.Path]
+	c.pending[f.Path] = src
+	if err := c.parse(); err != nil {
+		if hadOld {
+			c.pending[f.Path] = old
+		} else {
+			delete(c.pending, f.Path)
+		}
+		c.parse()
+		return fmt.Errorf("edit of %s produced an invalid configuration: %w", f.Path, err)
+	}
+	return nil
+}
+
+func lineStart(src []byte, off int) int {
+	return bytes.LastIndexByte(src[:off], '\n') + 1
+}
+
+func indentOf(src []byte, off int) string {
+	start := lineStart(src, off)
+	indent := src[start:off]
+	if len(bytes.TrimLeft(indent, " \t")) != 0 {
+		return ""
+	}
+	return string(indent)
+}
+
+// indentUnit returns the indentation of one nesting level in src: that of
+// its least indented line, or a tab if no line is indented.
+func indentUnit(src []byte) string {
+	unit := ""
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		rest := bytes.TrimLeft(line, " \t")
+		n := len(line) - len(rest)
+		if n > 0 && len(rest) > 0 && (unit == "" || n < len(unit)) {
+			unit = string(line[:n])
+		}
+	}
+	if unit == "" || strings.Contains(unit, "\t") {
+		return "\t"
+	}
+	return unit
+}
+
+// indentLines prefixes the lines of text with indent, replacing the tabs
+// that indent them with unit.
+func indentLines(text, indent, unit string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			rest := strings.TrimLeft(line, "\t")
+			lines[i] = indent + strings.Repeat(unit, len(line)-len(rest)) + rest
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RemoveStatement deletes a statement, together with its line when nothing
+// else shares it.
+func (c *Config) RemoveStatement(s *Statement) error {
+	src := s.File.Src
+	start, end := s.Start, s.End
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	ls := lineStart(src, start)
+	if len(bytes.TrimLeft(src[ls:start], " \t")) == 0 && (end == len(src) || src[end] == '\n' || src[end] == '\r') {
+		start = ls
+		if end < len(src) && src[end] == '\r' {
+			end++
+		}
+		if end < len(src) && src[end] == '\n' {
+			end++
+		}
+	}
+	return c.splice(s.File, start, end, "")
+}
+
+// ReplaceStatement swaps a statement for new text, indented like the
+// statement it replaces.
+func (c *Config) ReplaceStatement(s *Statement, text string) error {
+	indent := indentOf(s.File.Src, s.Start)
+	text = strings.TrimPrefix(indentLines(text, indent, indentUnit(s.File.Src)), indent)
+	return c.splice(s.File, s.Start, s.End, text)
+}
+
+// AppendStatement adds text as the last statement inside the block of
+// parent, or at the end of file when parent is nil.
+func (c *Config) AppendStatement(f *ConfFile, parent *Statement, text string) error {
+	if parent == nil {
+		src := f.Src
+		prefix := ""
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			prefix = "\n"
+		}
+		return c.splice(f, len(src), len(src), prefix+strings.TrimRight(text, "\n")+"\n")
+	}
+	block := parent.BlockValue()
+	if block == nil {
+		return fmt.Errorf("%s statement at %s has no block", parent.Name, parent.Pos)
+	}
+	f = parent.File
+	src := f.Src
+	closing := block.End - 1
+	children := block.Block
+	if ls := lineStart(src, closing); len(bytes.TrimLeft(src[ls:closing], " \t")) == 0 {
+		unit := indentUnit(src)
+		indent := indentOf(src, parent.Start) + unit
+		if len(children) > 0 {
+			last := children[len(children)-1]
+			if ind := indentOf(src, last.Start); ind != "" {
+				indent = ind
+			}
+		}
+		return c.splice(f, ls, ls, indentLines(text, indent, unit)+"\n")
+	}
+	prefix := " "
+	if closing > 0 && (src[closing-1] == ' ' || src[closing-1] == '\t') {
+		prefix = ""
+	}
+	return c.splice(f, closing, closing, prefix+strings.TrimSpace(text)+" ")
+}
+
+// SetOption sets the option name inside the block of s to value, replacing
+// an existing option of that name or appending a new one.
+func (c *Config) SetOption(s *Statement, name, value string) error {
+	text := strings.TrimSpace(name + " " + value)
+	if !strings.HasSuffix(text, ";") {
+		text += ";"
+	}
+	for _, child := range s.Block() {
+		if child.Name == name {
+			return c.ReplaceStatement(child, text)
+		}
+	}
+	return c.AppendStatement(s.File, s, text)
+}
+
+func (c *Config) UnsetOption(s *Statement, name string) error {
+	for _, child := range s.Block() {
+		if child.Name == name {
+			return c.RemoveStatement(child)
+		}
+	}
+	return fmt.Errorf("option %s not set in %s statement at %s", name, s.Name, s.Pos)
+}
+
+// Modified returns the paths of the files changed by edits since the
+// configuration was parsed.
+func (c *Config) Modified() []string {
+	var paths []string
+	for path := range c.pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (c *Config) Source(path string) []byte {
+	if src, ok := c.pending[path]; ok {
+		return src
+	}
+	for _, f := range c.Files {
+		if f.Path == path {
+			return f.Src
+		}
+	}
+	return nil
+}
+
+func (c *Config) FindZone(name, view string) *Zone {
+	name = strings.TrimSuffix(name, ".")
+	for _, z := range c.Zones() {
+		if strings.EqualFold(z.Name, name) && z.View == view {
+			return z
+		}
+	}
+	return nil
+}
+
+func Quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendStatementIndent(t *testing.T) {
+	zone := "zone \"f.test\" {\n\ttype forward;\n\tforwarders { 192.0.2.1; };\n};"
+	tests := []struct {
+		name string
+		conf string
+		want string
+	}{
+		{"tabs",
+			"view \"int\" {\n\tmatch-clients { any; };\n};\n",
+			"view \"int\" {\n\tmatch-clients { any; };\n\tzone \"f.test\" {\n\t\ttype forward;\n\t\tforwarders { 192.0.2.1; };\n\t};\n};\n"},
+		{"spaces",
+			"view \"int\" {\n    match-clients { any; };\n};\n",
+			"view \"int\" {\n    match-clients { any; };\n    zone \"f.test\" {\n        type forward;\n        forwarders { 192.0.2.1; };\n    };\n};\n"},
+		{"two spaces in empty view",
+			"options {\n  directory \"/tmp\";\n};\nview \"int\" {\n};\n",
+			"options {\n  directory \"/tmp\";\n};\nview \"int\" {\n  zone \"f.test\" {\n    type forward;\n    forwarders { 192.0.2.1; };\n  };\n};\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "named.conf")
+			if err := os.WriteFile(path, []byte(tt.conf), 0644); err != nil {
+				t.Fatal(err)
+			}
+			conf, err := ParseConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			views := conf.Views()
+			if len(views) != 1 {
+				t.Fatalf("found %d views, want 1", len(views))
+			}
+			if err := conf.AppendStatement(views[0].Stmt.File, views[0].Stmt, zone); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(conf.Source(path)); got != tt.want {
+				t.Errorf("config:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveZone(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "named.conf")
+	inc := filepath.Join(dir, "zones.conf")
+	files := map[string]string{
+		main: "view \"int\" {\n\tzone \"a.test\" {\n\t\ttype master;\n\t\tfile \"a.test\";\n\t};\n\tzone \"b.test\" { type master; file \"b.test\"; };\n\tinclude \"zones.conf\";\n};\n",
+		inc:  "zone \"c.test\" {\n\ttype master;\n\tfile \"c.test\";\n};\nzone \"d.test\" { type master; file \"d.test\"; };\n",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name     string
+		zone     string
+		file     string
+		want     string
+		modified string
+	}{
+		{"in view", "a.test", main,
+			"view \"int\" {\n\tzone \"b.test\" { type master; file \"b.test\"; };\n\tinclude \"zones.conf\";\n};\n", main},
+		{"one line in view", "b.test", main,
+			"view \"int\" {\n\tzone \"a.test\" {\n\t\ttype master;\n\t\tfile \"a.test\";\n\t};\n\tinclude \"zones.conf\";\n};\n", main},
+		{"in included file", "c.test", inc,
+			"zone \"d.test\" { type master; file \"d.test\"; };\n", inc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := ParseConfig(main)
+			if err != nil {
+				t.Fatal(err)
+			}
+			z := conf.FindZone(tt.zone, "int")
+			if z == nil {
+				t.Fatalf("zone %s not found in view int", tt.zone)
+			}
+			if err := conf.RemoveStatement(z.Stmt); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(conf.Source(tt.file)); got != tt.want {
+				t.Errorf("%s:\n%s\nwant:\n%s", filepath.Base(tt.file), got, tt.want)
+			}
+			if got := conf.Modified(); len(got) != 1 || got[0] != tt.modified {
+				t.Errorf("Modified() = %q, want [%q]", got, tt.modified)
+			}
+			if conf.FindZone(tt.zone, "int") != nil {
+				t.Errorf("zone %s still found after RemoveStatement()", tt.zone)
+			}
+			if n := len(conf.Views()[0].Zones); n != 3 {
+				t.Errorf("view int has %d zones after RemoveStatement(), want 3", n)
+			}
+		})
+	}
+}
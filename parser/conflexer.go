@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOpen
+	tokClose
+	tokSemicolon
+)
+
+type confToken struct {
+	kind tokenKind
+	text string
+	pos  Position
+}
+
+type confLexer struct {
+	file    string
+	src     []byte
+	off     int
+	line    int
+	col     int
+	pending []confToken
+}
+
+func newConfLexer(file string, src []byte) *confLexer {
+	return &confLexer{file: file, src: src, line: 1, col: 1}
+}
+
+func (l *confLexer) pos() Position {
+	return Position{File: l.file, Line: l.line, Column: l.col, Offset: l.off}
+}
+
+func (l *confLexer) advance() {
+	if l.src[l.off] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	l.off++
+}
+
+func (l *confLexer) peek(n int) byte {
+	if l.off+n < len(l.src) {
+		return l.src[l.off+n]
+	}
+	return 0
+}
+
+func (l *confLexer) unread(tok confToken) {
+	l.pending = append(l.pending, tok)
+}
+
+func (l *confLexer) next() (confToken, error) {
+	if n := len(l.pending); n > 0 {
+		tok := l.pending[n-1]
+		l.pending = l.pending[:n-1]
+		return tok, nil
+	}
+	if err := l.skipSpaceAndComments(); err != nil {
+		return confToken{}, err
+	}
+	start := l.pos()
+	if l.off >= len(l.src) {
+		return confToken{kind: tokEOF, pos: start}, nil
+	}
+	switch c := l.src[l.off]; c {
+	case '{':
+		l.advance()
+		return confToken{kind: tokOpen, text: "{", pos: start}, nil
+	case '}':
+		l.advance()
+		return confToken{kind: tokClose, text: "}", pos: start}, nil
+	case ';':
+		l.advance()
+		return confToken{kind: tokSemicolon, text: ";", pos: start}, nil
+	case '"':
+		l.advance()
+		var sb strings.Builder
+		for {
+			if l.off >= len(l.src) {
+				return confToken{}, &ParseError{Pos: start, Msg: "unterminated quoted string"}
+			}
+			c := l.src[l.off]
+			if c == '"' {
+				l.advance()
+				break
+			}
+			if c == '\\' && l.off+1 < len(l.src) {
+				l.advance()
+				c = l.src[l.off]
+			}
+			sb.WriteByte(c)
+			l.advance()
+		}
+		return confToken{kind: tokString, text: sb.String(), pos: start}, nil
+	}
+	for l.off < len(l.src) && !isConfDelimiter(l.src[l.off]) && !l.atComment() {
+		l.advance()
+	}
+	return confToken{kind: tokWord, text: string(l.src[start.Offset:l.off]), pos: start}, nil
+}
+
+func isConfDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n{};\"", c) >= 0
+}
+
+func (l *confLexer) atComment() bool {
+	c := l.src[l.off]
+	return c == '#' || (c == '/' && (l.peek(1) == '/' || l.peek(1) == '*'))
+}
+
+func (l *confLexer) skipSpaceAndComments() error {
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.advance()
+		case c == '#' || (c == '/' && l.peek(1) == '/'):
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.advance()
+			}
+		case c == '/' && l.peek(1) == '*':
+			start := l.pos()
+			l.advance()
+			l.advance()
+			for {
+				if l.off >= len(l.src) {
+					return &ParseError{Pos: start, Msg: "unterminated comment"}
+				}
+				if l.src[l.off] == '*' && l.peek(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+// errUnsupportedType marks records of a type b9m does not manage. named may
+// still accept them, so they are reported as warnings.
+var errUnsupportedType = errors.New("unsupported record type")
+
+// FirstError returns the first error diagnostic, or nil if there is none.
+func FirstError(diags []Diagnostic) error {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return d
+		}
+	}
+	return nil
+}
@@ -1,48 +1,195 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/AfazTech/b9m/config"
+	"github.com/miekg/dns"
 )
 
-func GetDomains() (map[string]string, error) {
-	configFile := config.GetConfigFile()
-	zoneDir := config.GetZoneDir()
-	config, err := ParseConfig(configFile)
-
+// LoadConfig parses the named.conf of inst.
+func LoadConfig(inst *config.Instance) (*Config, error) {
+	conf, err := ParseConfigIn(inst.ConfigFile, inst.Chroot)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse configuration file %s: %w", configFile, err)
-	}
-
-	domains := make(map[string]string)
-	for key, val := range config {
-		if strings.HasPrefix(key, "zone") {
-			var domain string
-			if strings.HasPrefix(key, "zone \"") {
-				parts := strings.SplitN(key, "\"", 3)
-				if len(parts) >= 2 {
-					domain = parts[1]
-				}
-			} else {
-				parts := strings.Fields(key)
-				if len(parts) >= 2 {
-					domain = parts[1]
-				}
+		return nil, err
+	}
+	conf.workDir = inst.ZoneDir
+	return conf, nil
+}
+
+// HostPath maps a path as named sees it to the host.
+func (c *Config) HostPath(p string) string {
+	if c.chroot == "" {
+		return p
+	}
+	return filepath.Join(c.chroot, p)
+}
+
+// NamedPath maps a host path to the path named sees, failing for paths
+// outside the chroot.
+func (c *Config) NamedPath(p string) (string, error) {
+	if c.chroot == "" {
+		return p, nil
+	}
+	rel, err := filepath.Rel(c.chroot, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is outside the chroot %s", p, c.chroot)
+	}
+	return filepath.Join("/", rel), nil
+}
+
+// Directory returns the working directory of named for zones in view, set
+// by the directory option of the view or of the options block. It is ""
+// when neither sets it.
+func (c *Config) Directory(view string) string {
+	if view != "" {
+		for _, v := range c.Views() {
+			if v.Name != view {
+				continue
 			}
-			if domain != "" {
-				if zoneMap, ok := val.(map[string]interface{}); ok {
-					if fileVal, ok2 := zoneMap["file"]; ok2 {
-						filePath, _ := fileVal.(string)
-						if !strings.HasPrefix(filePath, "/") {
-							filePath = zoneDir + "/" + filePath
-						}
-						domains[domain] = filePath
-					}
-				}
+			if dir := v.Stmt.Find("directory"); dir != nil {
+				return dir.Arg(0)
 			}
 		}
 	}
+	if options := c.Options(); options != nil {
+		if dir := options.Find("directory"); dir != nil {
+			return dir.Arg(0)
+		}
+	}
+	return ""
+}
+
+// WorkDir returns the host path of the directory named resolves relative
+// paths of zones in view against. Without a directory option it is the
+// zone directory of the instance.
+func (c *Config) WorkDir(view string) string {
+	dir := c.Directory(view)
+	if dir == "" {
+		return c.workDir
+	}
+	if !filepath.IsAbs(dir) {
+		return filepath.Join(c.workDir, dir)
+	}
+	return c.HostPath(dir)
+}
+
+// ZonePath returns the host path of the file zone z is loaded from,
+// resolving relative paths against the directory option like named does.
+func (c *Config) ZonePath(z *Zone) string {
+	if filepath.IsAbs(z.File) {
+		return c.HostPath(z.File)
+	}
+	return filepath.Join(c.WorkDir(z.View), z.File)
+}
+
+// LoadZone reads the zone file of z the way named does: relative $INCLUDE
+// paths are taken from the working directory and absolute ones from the
+// chroot, and names are relative to the zone name until the first $ORIGIN.
+func (c *Config) LoadZone(z *Zone) (*ZoneFile, error) {
+	return loadZoneFile(c.ZonePath(z), dns.Fqdn(z.Name), 0, &zoneLoader{dir: c.WorkDir(z.View), root: c.chroot})
+}
+
+var ErrDomainNotFound = errors.New("domain does not exist")
+
+// Domain is a zone b9m can manage, identified by its view and name. View is
+// empty for zones outside of views.
+type Domain struct {
+	Name       string          `json:"name"`
+	View       string          `json:"view,omitempty"`
+	Type       string          `json:"type"`
+	File       string          `json:"file,omitempty"`
+	Primaries  []string        `json:"primaries,omitempty"`
+	Forwarders []string        `json:"forwarders,omitempty"`
+	Forward    string          `json:"forward,omitempty"`
+	Transfer   *TransferStatus `json:"transfer,omitempty"`
+}
+
+// TransferStatus is the state of a secondary zone as reported by named.
+type TransferStatus struct {
+	State       string `json:"state"`
+	Serial      string `json:"serial,omitempty"`
+	LastLoaded  string `json:"last_loaded,omitempty"`
+	NextRefresh string `json:"next_refresh,omitempty"`
+	Expires     string `json:"expires,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func (d Domain) IsPrimary() bool {
+	return (&Zone{Type: d.Type}).IsPrimary()
+}
+
+// IsTransferred reports whether named transfers the zone from primaries,
+// as it does for secondary and stub zones.
+func (d Domain) IsTransferred() bool {
+	z := &Zone{Type: d.Type}
+	return z.IsSecondary() || z.IsStub()
+}
+
+func (d Domain) String() string {
+	if d.View == "" {
+		return d.Name
+	}
+	return d.Name + " in view " + d.View
+}
+
+// DomainName describes domain in view for messages.
+func DomainName(view, domain string) string {
+	return Domain{Name: domain, View: view}.String()
+}
+
+// LoadDomainZone reads the zone file of domain in view.
+func LoadDomainZone(inst *config.Instance, view, domain string) (*ZoneFile, error) {
+	conf, err := LoadConfig(inst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", inst.ConfigFile, err)
+	}
+	z := conf.FindZone(domain, view)
+	if z == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDomainNotFound, DomainName(view, domain))
+	}
+	if !z.IsPrimary() {
+		return nil, fmt.Errorf("%s is a %s zone, only primary zones can be edited", DomainName(view, domain), z.Type)
+	}
+	if z.File == "" {
+		return nil, fmt.Errorf("%w: %s has no zone file", ErrDomainNotFound, DomainName(view, domain))
+	}
+	return conf.LoadZone(z)
+}
+
+// GetDomains lists the zones with a zone file and the secondary, stub and
+// forward zones, in every view.
+func GetDomains(inst *config.Instance) ([]Domain, error) {
+	config, err := LoadConfig(inst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", inst.ConfigFile, err)
+	}
+
+	var domains []Domain
+	for _, zone := range config.Zones() {
+		if zone.Name == "" || (zone.File == "" && !zone.IsSecondary() && !zone.IsStub() && !zone.IsForward()) {
+			continue
+		}
+		d := Domain{Name: zone.Name, View: zone.View, Type: zone.Type, Primaries: zone.Primaries, Forwarders: zone.Forwarders, Forward: zone.Forward}
+		if zone.File != "" {
+			d.File = config.ZonePath(zone)
+		}
+		domains = append(domains, d)
+	}
 	return domains, nil
 }
+
+// FindDomain returns the domain named domain in view, or false if there is
+// no such zone.
+func FindDomain(domains []Domain, view, domain string) (Domain, bool) {
+	domain = strings.TrimSuffix(domain, ".")
+	for _, d := range domains {
+		if d.View == view && strings.EqualFold(d.Name, domain) {
+			return d, true
+		}
+	}
+	return Domain{}, false
+}
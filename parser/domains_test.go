@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AfazTech/b9m/config"
+)
+
+// writeFiles writes files, keyed by path relative to root, creating their
+// directories.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChrootPaths(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"etc/named.conf":   "include \"/etc/zones.conf\";\n",
+		"etc/zones.conf":   "zone \"example.com\" { type master; file \"/var/named/example.com.db\"; };\n",
+		"var/named/ex.inc": "www 60 A 192.0.2.1\n",
+		"var/named/example.com.db": "@ 3600 SOA ns1 admin 1 2 3 4 5\n" +
+			"$INCLUDE /var/named/ex.inc\n",
+	})
+	inst := &config.Instance{Profile: config.Profile{
+		Chroot:     root,
+		ConfigFile: filepath.Join(root, "etc/named.conf"),
+		ZoneDir:    filepath.Join(root, "var/named"),
+	}}
+	conf, err := LoadConfig(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := conf.FindZone("example.com", "")
+	if z == nil {
+		t.Fatal("zone in the included file not found")
+	}
+	if got, want := conf.ZonePath(z), filepath.Join(root, "var/named/example.com.db"); got != want {
+		t.Errorf("ZonePath() = %s, want %s", got, want)
+	}
+	zf, err := LoadDomainZone(inst, "", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := FirstError(zf.Diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(zf.Data().Records); got != 2 {
+		t.Errorf("zone has %d records, want 2 with the included one", got)
+	}
+
+	if got, err := conf.NamedPath(filepath.Join(root, "var/named/new.db")); err != nil || got != "/var/named/new.db" {
+		t.Errorf("NamedPath() = %q, %v, want /var/named/new.db", got, err)
+	}
+	if _, err := conf.NamedPath(filepath.Join(filepath.Dir(root), "outside.db")); err == nil {
+		t.Error("NamedPath() of a file outside the chroot succeeded")
+	}
+}
+
+func TestZonePathDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"named.conf": "options { directory \"/var/named\"; };\n" +
+			"view \"int\" {\n" +
+			"\tdirectory \"int\";\n" +
+			"\tzone \"example.com\" { type master; file \"example.com.db\"; };\n" +
+			"};\n" +
+			"view \"ext\" {\n" +
+			"\tzone \"example.com\" { type master; file \"example.com.db\"; };\n" +
+			"\tzone \"example.net\" { type master; file \"/srv/example.net.db\"; };\n" +
+			"};\n",
+		"plain.conf": "zone \"example.com\" { type master; file \"example.com.db\"; };\n",
+		"rel.conf": "options { directory \"zones\"; };\n" +
+			"zone \"example.com\" { type master; file \"example.com.db\"; };\n",
+	})
+	tests := []struct {
+		name, conf, chroot, view, zone, want string
+	}{
+		{"options directory", "named.conf", "", "ext", "example.com", "/var/named/example.com.db"},
+		{"view directory", "named.conf", "", "int", "example.com", filepath.Join(root, "zones/int/example.com.db")},
+		{"absolute file", "named.conf", "", "ext", "example.net", "/srv/example.net.db"},
+		{"options directory in chroot", "named.conf", root, "ext", "example.com", filepath.Join(root, "var/named/example.com.db")},
+		{"absolute file in chroot", "named.conf", root, "ext", "example.net", filepath.Join(root, "srv/example.net.db")},
+		{"no directory", "plain.conf", "", "", "example.com", filepath.Join(root, "zones/example.com.db")},
+		{"relative directory", "rel.conf", "", "", "example.com", filepath.Join(root, "zones/zones/example.com.db")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := LoadConfig(&config.Instance{Profile: config.Profile{
+				Chroot:     tt.chroot,
+				ConfigFile: filepath.Join(root, tt.conf),
+				ZoneDir:    filepath.Join(root, "zones"),
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			z := conf.FindZone(tt.zone, tt.view)
+			if z == nil {
+				t.Fatalf("zone %s not found in view %q", tt.zone, tt.view)
+			}
+			if got := conf.ZonePath(z); got != tt.want {
+				t.Errorf("ZonePath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// generate expands "$GENERATE range lhs [ttl] [class] type rhs" the way
+// named does, including the ${offset,width,base} modifiers.
+func generate(args []string, globalTTL int, origin string) ([]ZoneRecord, error) {
+	if len(args) < 4 {
+		return nil, fmt.Errorf("invalid $GENERATE format")
+	}
+	start, stop, step, err := parseGenerateRange(args[0])
+	if err != nil {
+		return nil, err
+	}
+	lhs, rest := args[1], args[2:]
+	ttl, class := "", ""
+	for len(rest) > 2 {
+		if isTTL(rest[0]) && ttl == "" {
+			ttl = rest[0]
+		} else if strings.EqualFold(rest[0], "IN") && class == "" {
+			class = "IN"
+		} else {
+			break
+		}
+		rest = rest[1:]
+	}
+	rType, rhs := strings.ToUpper(rest[0]), strings.Join(rest[1:], " ")
+	if ttl == "" {
+		ttl = strconv.Itoa(globalTTL)
+	}
+
+	var records []ZoneRecord
+	for n := 0; n <= (stop-start)/step; n++ {
+		i := start + n*step
+		name, err := expandGenerate(lhs, i)
+		if err != nil {
+			return nil, err
+		}
+		rdata, err := expandGenerate(rhs, i)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := parseRecord(tokenize(fmt.Sprintf("%s %s IN %s %s", name, ttl, rType, rdata)), "", globalTTL, origin)
+		if err != nil {
+			return nil, fmt.Errorf("$GENERATE %s: %w", args[0], err)
+		}
+		rec.Directive = "$GENERATE"
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// maxGenerate caps the number of records a single $GENERATE may expand to.
+const maxGenerate = 65536
+
+func parseGenerateRange(s string) (start, stop, step int, err error) {
+	step = 1
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		if step, err = strconv.Atoi(s[i+1:]); err != nil || step <= 0 {
+			return 0, 0, 0, fmt.Errorf("invalid $GENERATE step in %q", s)
+		}
+		s = s[:i]
+	}
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	if start, err = strconv.Atoi(bounds[0]); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	if stop, err = strconv.Atoi(bounds[1]); err != nil || start < 0 || stop < start || stop > math.MaxInt32 {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	if (stop-start)/step >= maxGenerate {
+		return 0, 0, 0, fmt.Errorf("$GENERATE range %q expands to more than %d records", s, maxGenerate)
+	}
+	return start, stop, step, nil
+}
+
+func expandGenerate(template string, i int) (string, error) {
+	var b strings.Builder
+	for pos := 0; pos < len(template); pos++ {
+		c := template[pos]
+		switch {
+		case c == '\\' && pos+1 < len(template) && template[pos+1] == '$':
+			b.WriteByte('$')
+			pos++
+		case c == '$' && pos+1 < len(template) && template[pos+1] == '{':
+			end := strings.IndexByte(template[pos:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated modifier in %q", template)
+			}
+			s, err := formatModifier(template[pos+2:pos+end], i)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+			pos += end
+		case c == '$':
+			b.WriteString(strconv.Itoa(i))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func formatModifier(modifier string, i int) (string, error) {
+	parts := strings.Split(modifier, ",")
+	if len(parts) > 3 {
+		return "", fmt.Errorf("invalid modifier ${%s}", modifier)
+	}
+	offset, width, base := 0, 0, "d"
+	var err error
+	if parts[0] != "" {
+		if offset, err = strconv.Atoi(parts[0]); err != nil {
+			return "", fmt.Errorf("invalid offset in ${%s}", modifier)
+		}
+	}
+	if len(parts) > 1 {
+		if width, err = strconv.Atoi(parts[1]); err != nil || width < 0 || width > 255 {
+			return "", fmt.Errorf("invalid width in ${%s}", modifier)
+		}
+	}
+	if len(parts) > 2 {
+		base = parts[2]
+	}
+	value := i + offset
+	if value < 0 {
+		return "", fmt.Errorf("${%s} gives negative value %d for %d", modifier, value, i)
+	}
+	switch base {
+	case "d", "o", "x", "X":
+		return fmt.Sprintf("%0*"+base, width, value), nil
+	case "n", "N":
+		return nibbles(value, width, base == "N"), nil
+	}
+	return "", fmt.Errorf("invalid base in ${%s}", modifier)
+}
+
+// nibbles writes value as dot separated hex digits, least significant
+// first, as used in ip6.arpa names. width counts the dots as well.
+func nibbles(value, width int, upper bool) string {
+	digits := "0123456789abcdef"
+	if upper {
+		digits = "0123456789ABCDEF"
+	}
+	var b strings.Builder
+	for {
+		b.WriteByte(digits[value&0xf])
+		value >>= 4
+		width--
+		if width <= 0 && value == 0 {
+			break
+		}
+		b.WriteByte('.')
+		width--
+	}
+	return b.String()
+}
@@ -0,0 +1,330 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type MXData struct {
+	Preference int    `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+type SRVData struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+const maxCharString = 255
+
+// TXTData holds the character strings of a TXT record, unquoted.
+type TXTData struct {
+	Segments []string `json:"segments"`
+}
+
+type CAAData struct {
+	Flags int    `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+type TLSAData struct {
+	Usage        int    `json:"usage"`
+	Selector     int    `json:"selector"`
+	MatchingType int    `json:"matching_type"`
+	Certificate  string `json:"certificate"`
+}
+
+type SSHFPData struct {
+	Algorithm   int    `json:"algorithm"`
+	Type        int    `json:"fp_type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// SVCBData holds HTTPS and SVCB records. Params are kept in presentation
+// form, for example "alpn=h2,h3".
+type SVCBData struct {
+	Priority int      `json:"priority"`
+	Target   string   `json:"target"`
+	Params   []string `json:"params,omitempty"`
+}
+
+type NAPTRData struct {
+	Order       int    `json:"order"`
+	Preference  int    `json:"preference"`
+	Flags       string `json:"flags"`
+	Service     string `json:"service"`
+	Regexp      string `json:"regexp"`
+	Replacement string `json:"replacement"`
+}
+
+type DSData struct {
+	KeyTag     int    `json:"key_tag"`
+	Algorithm  int    `json:"algorithm"`
+	DigestType int    `json:"digest_type"`
+	Digest     string `json:"digest"`
+}
+
+// LOCData keeps the RFC 1876 fields in presentation form, for example
+// Latitude "52 22 23.000 N" and Altitude "-2.00m".
+type LOCData struct {
+	Latitude       string `json:"latitude"`
+	Longitude      string `json:"longitude"`
+	Altitude       string `json:"altitude"`
+	Size           string `json:"size,omitempty"`
+	HorizPrecision string `json:"horiz_precision,omitempty"`
+	VertPrecision  string `json:"vert_precision,omitempty"`
+}
+
+// quoteString renders s as a character string, escaping quotes and
+// backslashes and writing non-printable bytes as \DDD.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (d MXData) String() string {
+	return fmt.Sprintf("%d %s", d.Preference, d.Exchange)
+}
+
+func (d SRVData) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.Priority, d.Weight, d.Port, d.Target)
+}
+
+func (d SOARecord) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", d.MName, d.RName, d.Serial, d.Refresh, d.Retry, d.Expire, d.Minimum)
+}
+
+// String renders the character strings, splitting any longer than the 255
+// bytes a single one can hold.
+func (d TXTData) String() string {
+	var segments []string
+	for _, s := range d.Segments {
+		for len(s) > maxCharString {
+			segments = append(segments, quoteString(s[:maxCharString]))
+			s = s[maxCharString:]
+		}
+		segments = append(segments, quoteString(s))
+	}
+	return strings.Join(segments, " ")
+}
+
+// FormatTXT turns a TXT value into rdata. A value starting with a quote is
+// taken to be in zone file format already; anything else is one string.
+func FormatTXT(value string) string {
+	if strings.HasPrefix(strings.TrimSpace(value), `"`) {
+		return TXTData{Segments: splitRData(value)}.String()
+	}
+	return TXTData{Segments: []string{value}}.String()
+}
+
+func (d CAAData) String() string {
+	return fmt.Sprintf("%d %s %s", d.Flags, d.Tag, quoteString(d.Value))
+}
+
+func (d TLSAData) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.Usage, d.Selector, d.MatchingType, d.Certificate)
+}
+
+func (d SSHFPData) String() string {
+	return fmt.Sprintf("%d %d %s", d.Algorithm, d.Type, d.Fingerprint)
+}
+
+func (d SVCBData) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%d %s %s", d.Priority, d.Target, strings.Join(d.Params, " ")))
+}
+
+func (d NAPTRData) String() string {
+	return fmt.Sprintf("%d %d %s %s %s %s", d.Order, d.Preference, quoteString(d.Flags), quoteString(d.Service), quoteString(d.Regexp), d.Replacement)
+}
+
+func (d DSData) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.KeyTag, d.Algorithm, d.DigestType, d.Digest)
+}
+
+func (d LOCData) String() string {
+	fields := []string{d.Latitude, d.Longitude, d.Altitude}
+	for _, f := range []string{d.Size, d.HorizPrecision, d.VertPrecision} {
+		if f == "" {
+			break
+		}
+		fields = append(fields, f)
+	}
+	return strings.Join(fields, " ")
+}
+
+// splitRData splits rdata into fields, keeping quoted character strings
+// together and removing their quotes.
+func splitRData(rdata string) []string {
+	var fields []string
+	var cur strings.Builder
+	inQuote, inField := false, false
+	for i := 0; i < len(rdata); i++ {
+		c := rdata[i]
+		switch {
+		case c == '\\' && i+3 < len(rdata) && isDigits(rdata[i+1:i+4]):
+			n, _ := strconv.Atoi(rdata[i+1 : i+4])
+			cur.WriteByte(byte(n))
+			i += 3
+			inField = true
+		case c == '\\' && i+1 < len(rdata):
+			i++
+			cur.WriteByte(rdata[i])
+			inField = true
+		case c == '"':
+			inQuote = !inQuote
+			inField = true
+		case (c == ' ' || c == '\t') && !inQuote:
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteByte(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func atoiFields(fields []string) ([]int, error) {
+	nums := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", f)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+func parseRData(rType, rdata string) (interface{}, error) {
+	fields := splitRData(rdata)
+	need := func(n int) error {
+		if len(fields) < n {
+			return fmt.Errorf("invalid %s format", rType)
+		}
+		return nil
+	}
+	switch rType {
+	case "CAA":
+		if err := need(3); err != nil {
+			return nil, err
+		}
+		nums, err := atoiFields(fields[:1])
+		if err != nil {
+			return nil, err
+		}
+		return CAAData{Flags: nums[0], Tag: fields[1], Value: strings.Join(fields[2:], " ")}, nil
+	case "TLSA":
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		nums, err := atoiFields(fields[:3])
+		if err != nil {
+			return nil, err
+		}
+		return TLSAData{Usage: nums[0], Selector: nums[1], MatchingType: nums[2], Certificate: strings.Join(fields[3:], "")}, nil
+	case "SSHFP":
+		if err := need(3); err != nil {
+			return nil, err
+		}
+		nums, err := atoiFields(fields[:2])
+		if err != nil {
+			return nil, err
+		}
+		return SSHFPData{Algorithm: nums[0], Type: nums[1], Fingerprint: strings.Join(fields[2:], "")}, nil
+	case "HTTPS", "SVCB":
+		if err := need(2); err != nil {
+			return nil, err
+		}
+		nums, err := atoiFields(fields[:1])
+		if err != nil {
+			return nil, err
+		}
+		return SVCBData{Priority: nums[0], Target: fields[1], Params: fields[2:]}, nil
+	case "NAPTR":
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("invalid NAPTR format")
+		}
+		nums, err := atoiFields(fields[:2])
+		if err != nil {
+			return nil, err
+		}
+		return NAPTRData{Order: nums[0], Preference: nums[1], Flags: fields[2], Service: fields[3], Regexp: fields[4], Replacement: fields[5]}, nil
+	case "DS":
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		nums, err := atoiFields(fields[:3])
+		if err != nil {
+			return nil, err
+		}
+		return DSData{KeyTag: nums[0], Algorithm: nums[1], DigestType: nums[2], Digest: strings.Join(fields[3:], "")}, nil
+	case "LOC":
+		return parseLOC(fields)
+	}
+	return nil, fmt.Errorf("unsupported record type %s", rType)
+}
+
+func parseLOC(fields []string) (LOCData, error) {
+	var loc LOCData
+	i := 0
+	coordinate := func(hemispheres string) (string, error) {
+		start := i
+		for i < len(fields) && i-start < 4 {
+			if strings.Contains(hemispheres, strings.ToUpper(fields[i])) {
+				i++
+				return strings.Join(fields[start:i], " "), nil
+			}
+			i++
+		}
+		return "", fmt.Errorf("invalid LOC format")
+	}
+	var err error
+	if loc.Latitude, err = coordinate("NS"); err != nil {
+		return loc, err
+	}
+	if loc.Longitude, err = coordinate("EW"); err != nil {
+		return loc, err
+	}
+	rest := fields[i:]
+	if len(rest) == 0 || len(rest) > 4 {
+		return loc, fmt.Errorf("invalid LOC format")
+	}
+	targets := []*string{&loc.Altitude, &loc.Size, &loc.HorizPrecision, &loc.VertPrecision}
+	for j, f := range rest {
+		*targets[j] = f
+	}
+	return loc, nil
+}
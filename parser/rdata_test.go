@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatTXT(t *testing.T) {
+	long := strings.Repeat("a", 255) + strings.Repeat("b", 300)
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "hello world", `"hello world"`},
+		{"quotes and backslashes", `say "hi" \o/`, `"say \"hi\" \\o/"`},
+		{"non-printable", "tab\there\xff", `"tab\009here\255"`},
+		{"zone file strings", `"v=spf1 -all" "second"`, `"v=spf1 -all" "second"`},
+		{"escapes in zone file strings", `"a\"b" "c\\d" "\065"`, `"a\"b" "c\\d" "A"`},
+		{"split at 255 bytes", long,
+			`"` + strings.Repeat("a", 255) + `" "` + strings.Repeat("b", 255) + `" "` + strings.Repeat("b", 45) + `"`},
+		{"exactly 255 bytes", strings.Repeat("c", 255), `"` + strings.Repeat("c", 255) + `"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatTXT(tt.value)
+			if got != tt.want {
+				t.Fatalf("FormatTXT(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+			if again := FormatTXT(got); again != got {
+				t.Errorf("FormatTXT() of its own output = %q, want %q", again, got)
+			}
+		})
+	}
+}
+
+func TestTXTRecord(t *testing.T) {
+	value := strings.Repeat("x", 300) + ` "quoted"`
+	rec, err := NewRecord("txt.example.com.", 300, "TXT", FormatTXT(value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, ok := rec.Value.(TXTData)
+	if !ok {
+		t.Fatalf("TXT record value is %T, want TXTData", rec.Value)
+	}
+	want := []string{strings.Repeat("x", 255), strings.Repeat("x", 45) + ` "quoted"`}
+	if !reflect.DeepEqual(txt.Segments, want) {
+		t.Errorf("segments = %q, want %q", txt.Segments, want)
+	}
+	for _, s := range txt.Segments {
+		if len(s) > maxCharString {
+			t.Errorf("segment of %d bytes exceeds %d", len(s), maxCharString)
+		}
+	}
+	if strings.Join(txt.Segments, "") != value {
+		t.Errorf("segments do not join to the original value")
+	}
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var classlessZone = regexp.MustCompile(`^(\d{1,3})-(\d{1,2})\.((\d{1,3})\.(\d{1,3})\.(\d{1,3})\.in-addr\.arpa)$`)
+
+// ReverseZones returns the names of the reverse zones covering cidr. Prefixes
+// between octet (IPv4) or nibble (IPv6) boundaries are split into the zones
+// of the next boundary. IPv4 prefixes longer than /24 get an RFC 2317
+// classless zone named after the first address and the prefix length, such
+// as 64-26.2.0.192.in-addr.arpa.
+func ReverseZones(cidr string) ([]string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+	ones, _ := network.Mask.Size()
+	if ones == 0 {
+		return nil, fmt.Errorf("invalid CIDR %q: prefix length must be greater than 0", cidr)
+	}
+	if ip := network.IP.To4(); ip != nil {
+		if ones > 24 {
+			return []string{fmt.Sprintf("%d-%d.%d.%d.%d.in-addr.arpa", ip[3], ones, ip[2], ip[1], ip[0])}, nil
+		}
+		return reverseZones(ip, ones, 8, "in-addr.arpa"), nil
+	}
+	return reverseZones(network.IP.To16(), ones, 4, "ip6.arpa"), nil
+}
+
+// reverseZones names the zones of the network ip/ones, with one label for
+// every width bits of the address.
+func reverseZones(ip net.IP, ones, width int, suffix string) []string {
+	digit := func(i int) int {
+		if width == 8 {
+			return int(ip[i])
+		}
+		if i%2 == 0 {
+			return int(ip[i/2] >> 4)
+		}
+		return int(ip[i/2] & 0xf)
+	}
+	labels := (ones + width - 1) / width
+	var zones []string
+	for n := 0; n < 1<<(labels*width-ones); n++ {
+		name := suffix
+		for i := 0; i < labels; i++ {
+			d := digit(i)
+			if i == labels-1 {
+				d += n
+			}
+			if width == 8 {
+				name = strconv.Itoa(d) + "." + name
+			} else {
+				name = strconv.FormatInt(int64(d), 16) + "." + name
+			}
+		}
+		zones = append(zones, name)
+	}
+	return zones
+}
+
+// ClasslessZone reports whether zone is an RFC 2317 classless reverse zone
+// as named by ReverseZones, returning the network it covers and the name of
+// the /24 zone that delegates it.
+func ClasslessZone(zone string) (*net.IPNet, string, bool) {
+	m := classlessZone.FindStringSubmatch(strings.ToLower(strings.TrimSuffix(zone, ".")))
+	if m == nil {
+		return nil, "", false
+	}
+	ip, network, err := net.ParseCIDR(fmt.Sprintf("%s.%s.%s.%s/%s", m[6], m[5], m[4], m[1], m[2]))
+	if err != nil || !ip.Equal(network.IP) {
+		return nil, "", false
+	}
+	if ones, _ := network.Mask.Size(); ones <= 24 {
+		return nil, "", false
+	}
+	return network, m[3], true
+}
+
+// ClasslessDelegation returns the records the parent of a classless reverse
+// zone needs to delegate it: NS records for the zone and a CNAME for every
+// address it covers.
+func ClasslessDelegation(zone string, nameservers []string) ([]ZoneRecord, error) {
+	network, parent, ok := ClasslessZone(zone)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a classless reverse zone", zone)
+	}
+	var records []ZoneRecord
+	for _, ns := range nameservers {
+		rec, err := NewRecord(zone+".", 86400, "NS", dns.Fqdn(ns))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	ones, _ := network.Mask.Size()
+	first := int(network.IP.To4()[3])
+	for host := first; host < first+1<<(32-ones); host++ {
+		owner := strconv.Itoa(host)
+		rec, err := NewRecord(owner+"."+parent+".", 86400, "CNAME", owner+"."+zone+".")
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// ReverseOwner returns the owner name, relative to zone, of the PTR record
+// for ip, or false if zone does not cover ip. Classless zones hold the PTR
+// records of their addresses under the last octet.
+func ReverseOwner(ip net.IP, zone string) (string, bool) {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	if network, _, ok := ClasslessZone(zone); ok {
+		if !network.Contains(ip) {
+			return "", false
+		}
+		return strconv.Itoa(int(ip.To4()[3])), true
+	}
+	name, err := dns.ReverseAddr(ip.String())
+	if err != nil {
+		return "", false
+	}
+	name = strings.TrimSuffix(name, ".")
+	if name == zone {
+		return "@", true
+	}
+	return strings.CutSuffix(name, "."+zone)
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestReverseZones(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want []string
+	}{
+		{"192.0.2.0/24", []string{"2.0.192.in-addr.arpa"}},
+		{"10.0.0.0/8", []string{"10.in-addr.arpa"}},
+		{"192.0.2.0/23", []string{"2.0.192.in-addr.arpa", "3.0.192.in-addr.arpa"}},
+		{"192.0.2.77/22", []string{"0.0.192.in-addr.arpa", "1.0.192.in-addr.arpa", "2.0.192.in-addr.arpa", "3.0.192.in-addr.arpa"}},
+		{"192.0.2.64/26", []string{"64-26.2.0.192.in-addr.arpa"}},
+		{"2001:db8::/32", []string{"8.b.d.0.1.0.0.2.ip6.arpa"}},
+		{"2001:db8::/31", []string{"8.b.d.0.1.0.0.2.ip6.arpa", "9.b.d.0.1.0.0.2.ip6.arpa"}},
+	}
+	for _, tt := range tests {
+		got, err := ReverseZones(tt.cidr)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReverseZones(%s) = %q, %v, want %q", tt.cidr, got, err, tt.want)
+		}
+	}
+	for _, cidr := range []string{"192.0.2.0", "0.0.0.0/0", "192.0.2.0/33"} {
+		if got, err := ReverseZones(cidr); err == nil {
+			t.Errorf("ReverseZones(%s) = %q, want an error", cidr, got)
+		}
+	}
+}
+
+func TestClasslessZone(t *testing.T) {
+	network, parent, ok := ClasslessZone("64-26.2.0.192.in-addr.arpa.")
+	if !ok || network.String() != "192.0.2.64/26" || parent != "2.0.192.in-addr.arpa" {
+		t.Errorf("ClasslessZone() = %v, %q, %v, want 192.0.2.64/26, 2.0.192.in-addr.arpa", network, parent, ok)
+	}
+	for _, zone := range []string{"2.0.192.in-addr.arpa", "65-26.2.0.192.in-addr.arpa", "0-24.2.0.192.in-addr.arpa", "64-26.example.com"} {
+		if _, _, ok := ClasslessZone(zone); ok {
+			t.Errorf("ClasslessZone(%s) reported a classless zone", zone)
+		}
+	}
+
+	records, err := ClasslessDelegation("64-26.2.0.192.in-addr.arpa", []string{"ns1.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 65 || records[0].Type != "NS" || records[0].RData != "ns1.example.com." {
+		t.Fatalf("ClasslessDelegation() gave %d records starting with %+v", len(records), records[0])
+	}
+	if last := records[64]; last.Name != "127.2.0.192.in-addr.arpa." || last.RData != "127.64-26.2.0.192.in-addr.arpa." {
+		t.Errorf("last CNAME = %s %s", last.Name, last.RData)
+	}
+}
+
+func TestReverseOwner(t *testing.T) {
+	tests := []struct {
+		ip, zone string
+		want     string
+		ok       bool
+	}{
+		{"192.0.2.10", "2.0.192.in-addr.arpa", "10", true},
+		{"192.0.2.10", "0.192.in-addr.arpa.", "10.2", true},
+		{"192.0.2.10", "10.2.0.192.in-addr.arpa", "@", true},
+		{"192.0.3.10", "2.0.192.in-addr.arpa", "", false},
+		{"192.0.2.70", "64-26.2.0.192.in-addr.arpa", "70", true},
+		{"192.0.2.10", "64-26.2.0.192.in-addr.arpa", "", false},
+		{"2001:db8::1", "8.b.d.0.1.0.0.2.ip6.arpa", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0", true},
+		{"2001:db9::1", "8.b.d.0.1.0.0.2.ip6.arpa", "", false},
+		// A zone that merely ends in the same digits does not cover the address.
+		{"192.0.2.10", "12.0.192.in-addr.arpa", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ReverseOwner(net.ParseIP(tt.ip), tt.zone)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("ReverseOwner(%s, %s) = %q, %v, want %q, %v", tt.ip, tt.zone, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SOAOptions change the SOA record and default TTL of a zone. Zero fields
+// are left as they are. Contact is an email address, stored as the RNAME.
+type SOAOptions struct {
+	Contact    string `json:"contact,omitempty"`
+	Refresh    int    `json:"refresh,omitempty"`
+	Retry      int    `json:"retry,omitempty"`
+	Expire     int    `json:"expire,omitempty"`
+	Minimum    int    `json:"minimum,omitempty"`
+	DefaultTTL int    `json:"default_ttl,omitempty"`
+}
+
+var (
+	emailLocalRegex  = regexp.MustCompile(`^[a-zA-Z0-9!#$%&'*+/=?^_{|}~.-]+$`)
+	emailDomainRegex = regexp.MustCompile(`^([a-zA-Z0-9_-]+\.)+[a-zA-Z0-9-]+$`)
+)
+
+// ContactRName converts an email address to the RNAME of an SOA record,
+// escaping the dots of the local part: john.doe@example.com becomes
+// john\.doe.example.com.
+func ContactRName(email string) (string, error) {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || !emailLocalRegex.MatchString(local) || !emailDomainRegex.MatchString(strings.TrimSuffix(domain, ".")) {
+		return "", fmt.Errorf("invalid contact email address %q", email)
+	}
+	return strings.ReplaceAll(local, ".", `\.`) + "." + strings.TrimSuffix(domain, ".") + ".", nil
+}
+
+// Validate checks that the timers of o fit in an SOA record and the contact
+// is an email address.
+func (o SOAOptions) Validate() error {
+	for _, f := range []struct {
+		name  string
+		value int
+	}{{"refresh", o.Refresh}, {"retry", o.Retry}, {"expire", o.Expire}, {"minimum", o.Minimum}, {"default TTL", o.DefaultTTL}} {
+		if f.value < 0 || f.value > math.MaxInt32 {
+			return fmt.Errorf("invalid SOA %s %d: must be between 0 and %d", f.name, f.value, math.MaxInt32)
+		}
+	}
+	if o.Contact != "" {
+		if _, err := ContactRName(o.Contact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSOA applies opts to the SOA record and the first $TTL directive of zf,
+// adding a $TTL directive before the first record if there is none.
+func (zf *ZoneFile) SetSOA(opts SOAOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	e := zf.SOA()
+	if e == nil {
+		return fmt.Errorf("SOA record not found")
+	}
+	soa := e.Record.Value.(SOARecord)
+	// Tokens are only replaced for the fields that change, so timers
+	// written with units such as 1h are kept otherwise.
+	changed := map[int]string{}
+	if opts.Contact != "" {
+		soa.RName, _ = ContactRName(opts.Contact)
+		changed[1] = soa.RName
+	}
+	for i, f := range []struct {
+		value int
+		field *int
+	}{{opts.Refresh, &soa.Refresh}, {opts.Retry, &soa.Retry}, {opts.Expire, &soa.Expire}, {opts.Minimum, &soa.Minimum}} {
+		if f.value > 0 {
+			*f.field = f.value
+			changed[3+i] = strconv.Itoa(f.value)
+		}
+	}
+	e.Record.Value = soa
+	mname := e.serialToken() - 2
+	if mname < 0 || mname+6 >= len(e.tokens) {
+		e.Record.RData = soa.String()
+		e.raw = ""
+	} else {
+		for i, v := range changed {
+			e.setToken(mname+i, v)
+		}
+		var rdata []string
+		for _, t := range e.tokens[mname:] {
+			rdata = append(rdata, t.text)
+		}
+		e.Record.RData = strings.Join(rdata, " ")
+	}
+	if opts.DefaultTTL > 0 {
+		zf.setDefaultTTL(opts.DefaultTTL)
+	}
+	return nil
+}
+
+func (zf *ZoneFile) setDefaultTTL(ttl int) {
+	first := len(zf.Entries)
+	for i, e := range zf.Entries {
+		if e.Kind == DirectiveEntry && e.Directive == "$TTL" {
+			e.Args = []string{strconv.Itoa(ttl)}
+			e.raw = ""
+			e.tokens = nil
+			return
+		}
+		if e.Kind == RecordEntry && i < first {
+			first = i
+		}
+	}
+	e := &Entry{Kind: DirectiveEntry, Directive: "$TTL", Args: []string{strconv.Itoa(ttl)}}
+	zf.Entries = append(zf.Entries[:first], append([]*Entry{e}, zf.Entries[first:]...)...)
+}
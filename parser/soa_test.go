@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestContactRName(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"hostmaster@example.com", "hostmaster.example.com."},
+		{"john.doe@example.com.", `john\.doe.example.com.`},
+		{"a+b@mail.example.org", "a+b.mail.example.org."},
+	}
+	for _, tt := range tests {
+		if got, err := ContactRName(tt.email); err != nil || got != tt.want {
+			t.Errorf("ContactRName(%q) = %q, %v, want %q", tt.email, got, err, tt.want)
+		}
+	}
+	for _, email := range []string{"", "hostmaster", "@example.com", "a b@example.com", "admin@localhost", "admin@exa mple.com"} {
+		if got, err := ContactRName(email); err == nil {
+			t.Errorf("ContactRName(%q) = %q, want an error", email, got)
+		}
+	}
+}
+
+func TestSOAOptionsValidate(t *testing.T) {
+	if err := (SOAOptions{}).Validate(); err != nil {
+		t.Errorf("Validate() of empty options = %v", err)
+	}
+	if err := (SOAOptions{Refresh: math.MaxInt32, Contact: "a@example.com"}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, o := range []SOAOptions{{Retry: -1}, {Expire: math.MaxInt32 + 1}, {DefaultTTL: -5}, {Contact: "nobody"}} {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", o)
+		}
+	}
+	if err := (SOAOptions{Minimum: -1}).Validate(); err == nil || !strings.Contains(err.Error(), "between 0 and") {
+		t.Errorf("Validate() = %v, want a range starting at 0", err)
+	}
+}
+
+func TestSetSOA(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+		opts SOAOptions
+		want string
+	}{
+		{"timers with units kept",
+			"$TTL 1h\n@ IN SOA ns1 admin (\n\t1 ; serial\n\t1h 15m 1w 5m )\n",
+			SOAOptions{Retry: 600, Contact: "john.doe@example.com"},
+			"$TTL 1h\n@ IN SOA ns1 john\\.doe.example.com. (\n\t1 ; serial\n\t1h 600 1w 5m )\n"},
+		{"default TTL replaced",
+			"; zone\n$TTL 1h\n@ IN SOA ns1 admin 1 2 3 4 5\n",
+			SOAOptions{DefaultTTL: 300, Minimum: 60},
+			"; zone\n$TTL 300\n@ IN SOA ns1 admin 1 2 3 4 60\n"},
+		{"default TTL added before the first record",
+			"; zone\n@ 3600 IN SOA ns1 admin 1 2 3 4 5\n@ IN NS ns1\n",
+			SOAOptions{DefaultTTL: 300},
+			"; zone\n$TTL 300\n@ 3600 IN SOA ns1 admin 1 2 3 4 5\n@ IN NS ns1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zf := ParseZone([]byte(tt.zone), "example.com")
+			if err := zf.SetSOA(tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(zf.Bytes()); got != tt.want {
+				t.Errorf("zone:\n%s\nwant:\n%s", got, tt.want)
+			}
+			reparsed := ParseZone(zf.Bytes(), "example.com")
+			if err := FirstError(reparsed.Diagnostics); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := reparsed.SOA().Record.Value, zf.SOA().Record.Value; got != want {
+				t.Errorf("SOA after reparsing = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if err := ParseZone([]byte("@ 3600 IN NS ns1\n"), "example.com").SetSOA(SOAOptions{Retry: 60}); err == nil {
+		t.Error("SetSOA() of a zone without SOA succeeded")
+	}
+}
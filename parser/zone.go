@@ -1,22 +1,21 @@
 package parser
 
 import (
-	"bufio"
 	"fmt"
-	"os"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
 type SOARecord struct {
-	MName   string
-	RName   string
-	Serial  int
-	Refresh int
-	Retry   int
-	Expire  int
-	Minimum int
+	MName   string `json:"mname"`
+	RName   string `json:"rname"`
+	Serial  int    `json:"serial"`
+	Refresh int    `json:"refresh"`
+	Retry   int    `json:"retry"`
+	Expire  int    `json:"expire"`
+	Minimum int    `json:"minimum"`
 }
 
 type ZoneRecord struct {
@@ -25,6 +24,17 @@ type ZoneRecord struct {
 	Class string
 	Type  string
 	Value interface{}
+	RData string
+	// Directive is "$GENERATE" or "$INCLUDE" for records that do not appear
+	// literally in the zone file and therefore cannot be edited in place.
+	Directive string
+	// Origin is the $ORIGIN in effect where the record appears, against
+	// which relative names in RData resolve. It is empty for records made
+	// with NewRecord.
+	Origin string
+
+	// ttlSet is false for records that inherit their TTL.
+	ttlSet bool
 }
 
 type ZoneData struct {
@@ -33,13 +43,13 @@ type ZoneData struct {
 	Records []ZoneRecord
 }
 
-var recordRegex = regexp.MustCompile(`^(\S+)\s+(\d+)?\s*(IN)?\s*(A|AAAA|CNAME|MX|NS|SOA|TXT|PTR|SRV)\s+(.+)$`)
-var soaRegex = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\d+)\s+(\d+[SMHDW]?)\s+(\d+[SMHDW]?)\s+(\d+[SMHDW]?)\s+(\d+[SMHDW]?)$`)
+var recordTypes = map[string]bool{
+	"A": true, "AAAA": true, "CNAME": true, "MX": true, "NS": true, "SOA": true, "TXT": true, "PTR": true, "SRV": true,
+	"CAA": true, "TLSA": true, "SSHFP": true, "HTTPS": true, "SVCB": true, "NAPTR": true, "DS": true, "LOC": true,
+}
+var recordClasses = map[string]bool{"IN": true, "CH": true, "HS": true, "CS": true}
 var mxRegex = regexp.MustCompile(`^(\d+)\s+(\S+)$`)
 var srvRegex = regexp.MustCompile(`^(\d+)\s+(\d+)\s+(\d+)\s+(\S+)$`)
-var originRegex = regexp.MustCompile(`^\$ORIGIN\s+(\S+)$`)
-var ttlRegex = regexp.MustCompile(`^\$TTL\s+(\d+[SMHDW]?)$`)
-var commentRegex = regexp.MustCompile(`^(.*?)(;.*)?$`)
 
 var timeUnits = map[rune]int{
 	'S': 1,
@@ -49,70 +59,141 @@ var timeUnits = map[rune]int{
 	'W': 604800,
 }
 
+// parseTTL accepts plain seconds as well as BIND unit notation such as
+// "1h30m" or "2D", in either case.
 func parseTTL(ttlStr string) (int, error) {
 	if ttl, err := strconv.Atoi(ttlStr); err == nil {
+		if ttl < 0 {
+			return 0, fmt.Errorf("invalid TTL %q", ttlStr)
+		}
 		return ttl, nil
 	}
-	numStr := ttlStr[:len(ttlStr)-1]
-	unit := rune(ttlStr[len(ttlStr)-1])
-	num, err := strconv.Atoi(numStr)
-	if err != nil {
-		return 0, err
+	total, num, digits := 0, 0, false
+	for _, c := range strings.ToUpper(ttlStr) {
+		if c >= '0' && c <= '9' {
+			num = num*10 + int(c-'0')
+			digits = true
+			continue
+		}
+		multiplier, ok := timeUnits[c]
+		if !ok || !digits {
+			return 0, fmt.Errorf("invalid TTL %q", ttlStr)
+		}
+		total += num * multiplier
+		num, digits = 0, false
 	}
-	multiplier, ok := timeUnits[unit]
-	if !ok {
-		return 0, fmt.Errorf("unknown TTL unit")
+	if digits || total == 0 && ttlStr == "" {
+		return 0, fmt.Errorf("invalid TTL %q", ttlStr)
 	}
-	return num * multiplier, nil
+	return total, nil
 }
 
-func parseRecord(tokens []string, globalTTL int, globalOrigin string) (ZoneRecord, error) {
-	line := strings.Join(tokens, " ")
-	matches := recordRegex.FindStringSubmatch(line)
-	if matches == nil {
-		return ZoneRecord{}, fmt.Errorf("invalid record format")
+func isTTL(s string) bool {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return false
 	}
+	_, err := parseTTL(s)
+	return err == nil
+}
 
-	record := ZoneRecord{
-		Name:  matches[1],
-		Class: "IN",
-		Type:  matches[4],
+// isAbsolute reports whether name ends in an unescaped dot.
+func isAbsolute(name string) bool {
+	if !strings.HasSuffix(name, ".") {
+		return false
 	}
-	if matches[2] != "" {
-		ttl, _ := parseTTL(matches[2])
-		record.TTL = ttl
-	} else {
-		record.TTL = globalTTL
+	backslashes := 0
+	for i := len(name) - 2; i >= 0 && name[i] == '\\'; i-- {
+		backslashes++
 	}
-	if matches[3] != "" {
-		record.Class = matches[3]
+	return backslashes%2 == 0
+}
+
+// parseRecord parses the tokens of one record line: an owner followed by an
+// optional TTL and class in either order, the type and the rdata. A blank
+// owner is passed as "" and inherits owner from the previous record; an
+// omitted TTL is defaultTTL.
+func parseRecord(tokens []string, owner string, defaultTTL int, globalOrigin string) (ZoneRecord, error) {
+	if len(tokens) == 0 {
+		return ZoneRecord{}, fmt.Errorf("invalid record format")
 	}
-	if !strings.HasSuffix(record.Name, ".") && globalOrigin != "" && record.Name != globalOrigin {
+	record := ZoneRecord{
+		Name:   tokens[0],
+		TTL:    defaultTTL,
+		Class:  "IN",
+		Origin: globalOrigin,
+	}
+	rest := tokens[1:]
+	if tokens[0] == "" {
+		if owner == "" {
+			return ZoneRecord{}, fmt.Errorf("no previous owner name to inherit")
+		}
+		record.Name = owner
+	} else if record.Name == "@" {
+		if globalOrigin != "" {
+			record.Name = globalOrigin
+		}
+	} else if !isAbsolute(record.Name) && globalOrigin != "" {
 		record.Name += "." + globalOrigin
 	}
 
-	rdata := strings.TrimSpace(matches[5])
+	classSet := false
+	for len(rest) > 0 {
+		if !record.ttlSet && isTTL(rest[0]) {
+			record.TTL, _ = parseTTL(rest[0])
+			record.ttlSet = true
+		} else if !classSet && recordClasses[strings.ToUpper(rest[0])] {
+			record.Class = strings.ToUpper(rest[0])
+			classSet = true
+		} else {
+			break
+		}
+		rest = rest[1:]
+	}
+	if len(rest) < 2 {
+		return ZoneRecord{}, fmt.Errorf("invalid record format")
+	}
+	record.Type = strings.ToUpper(rest[0])
+	if !recordTypes[record.Type] {
+		return ZoneRecord{}, fmt.Errorf("%w %s", errUnsupportedType, rest[0])
+	}
+
+	rdata := strings.Join(rest[1:], " ")
+	record.RData = rdata
 	switch record.Type {
 	case "SOA":
-		soaMatches := soaRegex.FindStringSubmatch(rdata)
-		if soaMatches == nil {
+		fields := strings.Fields(rdata)
+		if len(fields) != 7 {
 			return ZoneRecord{}, fmt.Errorf("invalid SOA format")
 		}
-		serial, _ := strconv.Atoi(soaMatches[3])
-		refresh, _ := parseTTL(soaMatches[4])
-		retry, _ := parseTTL(soaMatches[5])
-		expire, _ := parseTTL(soaMatches[6])
-		minimum, _ := parseTTL(soaMatches[7])
+		serial, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return ZoneRecord{}, fmt.Errorf("invalid SOA serial %q", fields[2])
+		}
+		var timers [4]int
+		for i, f := range fields[3:] {
+			if timers[i], err = parseTTL(f); err != nil {
+				return ZoneRecord{}, fmt.Errorf("invalid SOA format: %w", err)
+			}
+		}
 		record.Value = SOARecord{
-			MName:   soaMatches[1],
-			RName:   soaMatches[2],
-			Serial:  serial,
-			Refresh: refresh,
-			Retry:   retry,
-			Expire:  expire,
-			Minimum: minimum,
-		}
-	case "NS", "A", "AAAA", "CNAME", "PTR":
+			MName:   fields[0],
+			RName:   fields[1],
+			Serial:  int(serial),
+			Refresh: timers[0],
+			Retry:   timers[1],
+			Expire:  timers[2],
+			Minimum: timers[3],
+		}
+	case "A", "AAAA":
+		ip := net.ParseIP(rdata)
+		if ip == nil || (ip.To4() != nil) != (record.Type == "A") {
+			return ZoneRecord{}, fmt.Errorf("invalid %s address %q", record.Type, rdata)
+		}
+		record.Value = rdata
+	case "NS", "CNAME", "PTR":
+		if len(rest) != 2 {
+			return ZoneRecord{}, fmt.Errorf("invalid %s format", record.Type)
+		}
 		record.Value = rdata
 	case "MX":
 		mxMatches := mxRegex.FindStringSubmatch(rdata)
@@ -120,9 +201,9 @@ func parseRecord(tokens []string, globalTTL int, globalOrigin string) (ZoneRecor
 			return ZoneRecord{}, fmt.Errorf("invalid MX format")
 		}
 		pref, _ := strconv.Atoi(mxMatches[1])
-		record.Value = map[string]interface{}{
-			"preference": pref,
-			"exchange":   mxMatches[2],
+		record.Value = MXData{
+			Preference: pref,
+			Exchange:   mxMatches[2],
 		}
 	case "SRV":
 		srvMatches := srvRegex.FindStringSubmatch(rdata)
@@ -132,89 +213,40 @@ func parseRecord(tokens []string, globalTTL int, globalOrigin string) (ZoneRecor
 		priority, _ := strconv.Atoi(srvMatches[1])
 		weight, _ := strconv.Atoi(srvMatches[2])
 		port, _ := strconv.Atoi(srvMatches[3])
-		record.Value = map[string]interface{}{
-			"priority": priority,
-			"weight":   weight,
-			"port":     port,
-			"target":   srvMatches[4],
+		record.Value = SRVData{
+			Priority: priority,
+			Weight:   weight,
+			Port:     port,
+			Target:   srvMatches[4],
 		}
 	case "TXT":
-		record.Value = strings.Trim(rdata, "\"")
+		record.Value = TXTData{Segments: splitRData(rdata)}
+	default:
+		value, err := parseRData(record.Type, rdata)
+		if err != nil {
+			return ZoneRecord{}, err
+		}
+		record.Value = value
 	}
 	return record, nil
 }
 
-func ParseZoneFile(filePath string) (ZoneData, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return ZoneData{}, err
-	}
-	defer file.Close()
-
-	zone := ZoneData{Records: []ZoneRecord{}}
-	scanner := bufio.NewScanner(file)
-	multiLineRegex := regexp.MustCompile(`\((.*?)\)`)
-	globalOrigin := ""
-	globalTTL := 0
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-
-		commentMatches := commentRegex.FindStringSubmatch(line)
-		line = strings.TrimSpace(commentMatches[1])
-		if line == "" {
-			continue
-		}
-
-		if multiLineMatches := multiLineRegex.FindStringSubmatch(line); multiLineMatches != nil {
-			line = strings.TrimSpace(multiLineMatches[1])
-		} else if strings.Contains(line, "(") && !strings.Contains(line, ")") {
-			fullLine := line
-			for scanner.Scan() {
-				nextLine := strings.TrimSpace(scanner.Text())
-				if nextLine == "" {
-					continue
-				}
-				commentMatches = commentRegex.FindStringSubmatch(nextLine)
-				nextLine = strings.TrimSpace(commentMatches[1])
-				fullLine += " " + nextLine
-				if strings.Contains(nextLine, ")") {
-					break
-				}
-			}
-			line = strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(fullLine, "(", ""), ")", ""))
-		}
-
-		if originMatches := originRegex.FindStringSubmatch(line); originMatches != nil {
-			globalOrigin = originMatches[1]
-			if !strings.HasSuffix(globalOrigin, ".") {
-				globalOrigin += "."
-			}
-			continue
-		}
-
-		if ttlMatches := ttlRegex.FindStringSubmatch(line); ttlMatches != nil {
-			globalTTL, _ = parseTTL(ttlMatches[1])
-			continue
-		}
-
-		tokens := strings.Fields(line)
-		if recordRegex.MatchString(line) {
-			rec, err := parseRecord(tokens, globalTTL, globalOrigin)
-			if err == nil {
-				zone.Records = append(zone.Records, rec)
-			}
-		}
+// tokenize splits a single record line into tokens the same way the zone
+// file reader does, so quoted strings keep their spaces.
+func tokenize(line string) []string {
+	var tokens []string
+	for _, e := range splitEntries([]byte(line)) {
+		tokens = append(tokens, e.tokenTexts()...)
 	}
+	return tokens
+}
 
-	if err := scanner.Err(); err != nil {
-		return zone, err
-	}
-	zone.Origin = globalOrigin
-	zone.TTL = globalTTL
+// NormalizeRData joins the tokens of rdata with single spaces, leaving the
+// contents of quoted strings alone.
+func NormalizeRData(rdata string) string {
+	return strings.Join(tokenize(rdata), " ")
+}
 
-	return zone, nil
+func NewRecord(name string, ttl int, rType, rdata string) (ZoneRecord, error) {
+	return parseRecord(tokenize(fmt.Sprintf("%s %d IN %s %s", name, ttl, rType, rdata)), "", ttl, "")
 }
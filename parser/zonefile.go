@@ -0,0 +1,596 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AfazTech/b9m/serial"
+	"github.com/miekg/dns"
+)
+
+type EntryKind int
+
+const (
+	BlankEntry EntryKind = iota
+	CommentEntry
+	DirectiveEntry
+	RecordEntry
+	UnknownEntry
+)
+
+// Entry is one logical line of a zone file. Records spanning several
+// physical lines with parentheses form a single entry.
+type Entry struct {
+	Kind      EntryKind
+	Line      int
+	Directive string
+	Args      []string
+	Comment   string
+	Record    *ZoneRecord
+	// Generated holds the records expanded from a $GENERATE directive and
+	// Include the file read by an $INCLUDE directive.
+	Generated []ZoneRecord
+	Include   *ZoneFile
+
+	raw     string
+	tokens  []token
+	problem string
+}
+
+type token struct {
+	text   string
+	off    int
+	quoted bool
+}
+
+// ZoneFile keeps every entry of a zone file in source order so that it can
+// be written back unchanged apart from the edits made to it.
+type ZoneFile struct {
+	Path        string
+	Entries     []*Entry
+	Diagnostics []Diagnostic
+}
+
+var schemeCommentRegex = regexp.MustCompile(`^;\s*b9m:\s*serial-scheme=(\S+)\s*$`)
+
+type zoneLoader struct {
+	dir   string
+	root  string
+	stack []string
+}
+
+func loadZoneFile(path, origin string, ttl int, l *zoneLoader) (*ZoneFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(l.stack, abs) {
+		return nil, fmt.Errorf("$INCLUDE loop: %s", strings.Join(append(l.stack, abs), " -> "))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l.stack = append(l.stack, abs)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	return parseZone(data, path, origin, ttl, l), nil
+}
+
+// ParseZone parses zone data of the zone origin without following $INCLUDE
+// directives.
+func ParseZone(data []byte, origin string) *ZoneFile {
+	return parseZone(data, "", dns.Fqdn(origin), 0, nil)
+}
+
+// parseZone never fails: problems are recorded in the Diagnostics of the
+// returned file and the offending lines are kept as unknown entries. As in
+// RFC 1035, records without a TTL take that of $TTL or, before any $TTL, the
+// last TTL stated explicitly. Until a TTL is known, as BIND does, the SOA
+// record takes its minimum and the records after it follow.
+func parseZone(data []byte, path, origin string, ttl int, l *zoneLoader) *ZoneFile {
+	zf := &ZoneFile{Path: path}
+	owner := ""
+	ttlDirective := false
+	ttlKnown := ttl != 0
+	report := func(e *Entry, tok int, severity Severity, err error) {
+		line, col := e.position(tok)
+		zf.Diagnostics = append(zf.Diagnostics, Diagnostic{File: path, Line: line, Column: col, Severity: severity, Message: err.Error()})
+	}
+	for _, e := range splitEntries(data) {
+		zf.Entries = append(zf.Entries, e)
+		if e.problem != "" {
+			report(e, len(e.tokens)-1, SeverityError, errors.New(e.problem))
+		}
+		if len(e.tokens) == 0 {
+			if e.Comment != "" {
+				e.Kind = CommentEntry
+			}
+			continue
+		}
+		tokens := e.tokenTexts()
+		blankOwner := e.raw[0] == ' ' || e.raw[0] == '\t'
+		if !blankOwner && strings.HasPrefix(tokens[0], "$") {
+			e.Kind = DirectiveEntry
+			e.Directive = strings.ToUpper(tokens[0])
+			e.Args = tokens[1:]
+			switch e.Directive {
+			case "$ORIGIN":
+				if len(e.Args) != 1 {
+					report(e, 0, SeverityError, fmt.Errorf("$ORIGIN needs exactly one domain name"))
+					continue
+				}
+				origin = Fqdn(e.Args[0], origin)
+			case "$TTL":
+				if len(e.Args) != 1 {
+					report(e, 0, SeverityError, fmt.Errorf("$TTL needs exactly one value"))
+					continue
+				}
+				t, err := parseTTL(e.Args[0])
+				if err != nil {
+					report(e, 1, SeverityError, err)
+					continue
+				}
+				ttl = t
+				ttlDirective, ttlKnown = true, true
+			case "$GENERATE":
+				recs, err := generate(e.Args, ttl, origin)
+				if err != nil {
+					report(e, 0, SeverityError, err)
+					continue
+				}
+				e.Generated = recs
+			case "$INCLUDE":
+				if l == nil {
+					continue
+				}
+				if len(e.Args) == 0 {
+					report(e, 0, SeverityError, fmt.Errorf("$INCLUDE without a file name"))
+					continue
+				}
+				incPath := strings.Trim(e.Args[0], `"`)
+				if !filepath.IsAbs(incPath) {
+					incPath = filepath.Join(l.dir, incPath)
+				} else if l.root != "" {
+					incPath = filepath.Join(l.root, incPath)
+				}
+				includeOrigin := origin
+				if len(e.Args) > 1 {
+					includeOrigin = Fqdn(e.Args[1], origin)
+				}
+				inc, err := loadZoneFile(incPath, includeOrigin, ttl, l)
+				if err != nil {
+					report(e, 1, SeverityError, err)
+					continue
+				}
+				e.Include = inc
+				zf.Diagnostics = append(zf.Diagnostics, inc.Diagnostics...)
+			default:
+				report(e, 0, SeverityError, fmt.Errorf("unknown directive %s", tokens[0]))
+			}
+			continue
+		}
+		e.Kind = UnknownEntry
+		if blankOwner {
+			tokens = append([]string{""}, tokens...)
+		}
+		rec, err := parseRecord(tokens, owner, ttl, origin)
+		if err != nil {
+			if errors.Is(err, errUnsupportedType) {
+				report(e, 0, SeverityWarning, fmt.Errorf("%w; the record is kept but cannot be managed", err))
+			} else {
+				report(e, 0, SeverityError, err)
+			}
+			continue
+		}
+		if soa, ok := rec.Value.(SOARecord); ok && !rec.ttlSet && !ttlKnown {
+			rec.TTL, ttl = soa.Minimum, soa.Minimum
+		}
+		e.Kind = RecordEntry
+		e.Record = &rec
+		owner = rec.Name
+		if rec.ttlSet && !ttlDirective {
+			ttl = rec.TTL
+		}
+		ttlKnown = ttlKnown || rec.ttlSet || rec.Type == "SOA"
+	}
+	return zf
+}
+
+// position returns the line and column of token tok of the entry, or of the
+// start of the entry if there is no such token.
+func (e *Entry) position(tok int) (int, int) {
+	off := 0
+	if tok >= 0 && tok < len(e.tokens) {
+		off = e.tokens[tok].off
+	}
+	before := e.raw[:off]
+	line := e.Line + strings.Count(before, "\n")
+	return line, off - strings.LastIndexByte(before, '\n')
+}
+
+func splitEntries(data []byte) []*Entry {
+	var entries []*Entry
+	pos, line := 0, 1
+	for pos < len(data) {
+		e := &Entry{Line: line}
+		start, depth := pos, 0
+		for pos < len(data) {
+			c := data[pos]
+			if c == '\n' {
+				line++
+				pos++
+				if depth == 0 {
+					break
+				}
+				continue
+			}
+			switch c {
+			case ' ', '\t', '\r':
+				pos++
+			case ';':
+				end := bytes.IndexByte(data[pos:], '\n')
+				if end < 0 {
+					end = len(data) - pos
+				}
+				if e.Comment != "" {
+					e.Comment += "\n"
+				}
+				e.Comment += strings.TrimRight(string(data[pos:pos+end]), "\r")
+				pos += end
+			case '(':
+				depth++
+				pos++
+			case ')':
+				if depth > 0 {
+					depth--
+				} else {
+					e.problem = "unbalanced closing parenthesis"
+				}
+				pos++
+			case '"':
+				tokStart := pos
+				pos++
+				for pos < len(data) && data[pos] != '"' {
+					if data[pos] == '\\' && pos+1 < len(data) {
+						pos++
+					}
+					if data[pos] == '\n' {
+						line++
+					}
+					pos++
+				}
+				if pos < len(data) {
+					pos++
+				} else {
+					e.problem = "unterminated quoted string"
+				}
+				e.tokens = append(e.tokens, token{text: string(data[tokStart:pos]), off: tokStart - start, quoted: true})
+			default:
+				tokStart := pos
+				for pos < len(data) && !strings.ContainsRune(" \t\r\n;()\"", rune(data[pos])) {
+					if data[pos] == '\\' && pos+1 < len(data) {
+						pos++
+					}
+					pos++
+				}
+				e.tokens = append(e.tokens, token{text: string(data[tokStart:pos]), off: tokStart - start})
+			}
+		}
+		if depth > 0 {
+			e.problem = "missing closing parenthesis"
+		}
+		e.raw = string(data[start:pos])
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (e *Entry) tokenTexts() []string {
+	texts := make([]string, len(e.tokens))
+	for i, t := range e.tokens {
+		texts[i] = t.text
+	}
+	return texts
+}
+
+// setToken replaces the text of a single token in place, keeping the rest of
+// the entry's formatting and comments.
+func (e *Entry) setToken(i int, text string) {
+	t := e.tokens[i]
+	e.raw = e.raw[:t.off] + text + e.raw[t.off+len(t.text):]
+	shift := len(text) - len(t.text)
+	e.tokens[i].text = text
+	for j := i + 1; j < len(e.tokens); j++ {
+		e.tokens[j].off += shift
+	}
+}
+
+func (e *Entry) Text() string {
+	if e.raw != "" {
+		return e.raw
+	}
+	switch e.Kind {
+	case CommentEntry:
+		return e.Comment + "\n"
+	case DirectiveEntry:
+		return strings.TrimSpace(e.Directive+" "+strings.Join(e.Args, " ")) + "\n"
+	case RecordEntry:
+		rec := e.Record
+		if !rec.ttlSet {
+			return fmt.Sprintf("%s %s %s %s\n", rec.Name, rec.Class, rec.Type, rec.RData)
+		}
+		return fmt.Sprintf("%s %d %s %s %s\n", rec.Name, rec.TTL, rec.Class, rec.Type, rec.RData)
+	}
+	return "\n"
+}
+
+// insertToken inserts text at offset off of the entry as token i, keeping it
+// apart from what follows.
+func (e *Entry) insertToken(i, off int, text string) {
+	ins := text
+	if off < len(e.raw) && e.raw[off] != ' ' && e.raw[off] != '\t' {
+		ins += " "
+	}
+	e.raw = e.raw[:off] + ins + e.raw[off:]
+	for j := i; j < len(e.tokens); j++ {
+		e.tokens[j].off += len(ins)
+	}
+	e.tokens = slices.Insert(e.tokens, i, token{text: text, off: off})
+}
+
+func (e *Entry) blankOwner() bool {
+	return len(e.tokens) > 0 && (e.raw[0] == ' ' || e.raw[0] == '\t')
+}
+
+// SetRecord replaces the record held by the entry. The entry is rendered in
+// canonical form from then on. Use ZoneFile.Replace if the owner or TTL may
+// change, as the following entries could inherit them.
+func (e *Entry) SetRecord(rec ZoneRecord) {
+	e.Kind = RecordEntry
+	e.Record = &rec
+	e.raw = ""
+	e.tokens = nil
+}
+
+// SetRData replaces the rdata of the record held by the entry, keeping its
+// owner, class and TTL, which stays inherited if it was.
+func (e *Entry) SetRData(rdata string) error {
+	rec, err := NewRecord(e.Record.Name, e.Record.TTL, e.Record.Type, rdata)
+	if err != nil {
+		return err
+	}
+	rec.Class = e.Record.Class
+	rec.Origin = e.Record.Origin
+	rec.ttlSet = e.Record.ttlSet
+	e.SetRecord(rec)
+	return nil
+}
+
+func (zf *ZoneFile) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, e := range zf.Entries {
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(e.Text())
+	}
+	return buf.Bytes()
+}
+
+// Files returns the path of zf followed by those of the files it includes.
+func (zf *ZoneFile) Files() []string {
+	files := []string{zf.Path}
+	for _, e := range zf.Entries {
+		if e.Include != nil {
+			files = append(files, e.Include.Files()...)
+		}
+	}
+	return files
+}
+
+func (zf *ZoneFile) Data() ZoneData {
+	zone := ZoneData{Records: []ZoneRecord{}}
+	for _, e := range zf.Entries {
+		switch e.Kind {
+		case DirectiveEntry:
+			switch {
+			case e.Directive == "$ORIGIN" && len(e.Args) == 1:
+				zone.Origin = Fqdn(e.Args[0], zone.Origin)
+			case e.Directive == "$TTL" && len(e.Args) == 1:
+				if ttl, err := parseTTL(e.Args[0]); err == nil {
+					zone.TTL = ttl
+				}
+			}
+			zone.Records = append(zone.Records, e.Generated...)
+			if e.Include != nil {
+				for _, rec := range e.Include.Data().Records {
+					rec.Directive = "$INCLUDE"
+					zone.Records = append(zone.Records, rec)
+				}
+			}
+		case RecordEntry:
+			zone.Records = append(zone.Records, *e.Record)
+		}
+	}
+	return zone
+}
+
+func (zf *ZoneFile) Records() []*Entry {
+	var entries []*Entry
+	for _, e := range zf.Entries {
+		if e.Kind == RecordEntry {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (zf *ZoneFile) AppendRecord(rec ZoneRecord) *Entry {
+	e := &Entry{}
+	e.SetRecord(rec)
+	zf.Entries = append(zf.Entries, e)
+	return e
+}
+
+// Replace replaces the record held by target like Entry.SetRecord, first
+// making the entries that inherit an owner or TTL that changes state it.
+func (zf *ZoneFile) Replace(target *Entry, rec ZoneRecord) {
+	if target.Kind == RecordEntry {
+		zf.detach(target, rec.Name != target.Record.Name, !rec.ttlSet || rec.TTL != target.Record.TTL)
+		if rec.Origin == "" {
+			rec.Origin = target.Record.Origin
+		}
+	}
+	target.SetRecord(rec)
+}
+
+func (zf *ZoneFile) Remove(target *Entry) bool {
+	for i, e := range zf.Entries {
+		if e == target {
+			if e.Kind == RecordEntry {
+				zf.detach(e, true, true)
+			}
+			zf.Entries = slices.Delete(zf.Entries, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// detach writes the owner and TTL of the record entry target out on the
+// following entries that inherit them, so that they keep them when target
+// changes or goes away. The TTL of target is only inherited before $TTL.
+func (zf *ZoneFile) detach(target *Entry, owner, ttl bool) {
+	i := slices.Index(zf.Entries, target)
+	if i < 0 {
+		return
+	}
+	for _, e := range zf.Entries[:i] {
+		if e.Kind == DirectiveEntry && e.Directive == "$TTL" {
+			ttl = false
+		}
+	}
+	ttl = ttl && target.Record.ttlSet
+	for _, e := range zf.Entries[i+1:] {
+		if !owner && !ttl {
+			return
+		}
+		switch e.Kind {
+		case DirectiveEntry:
+			if e.Directive == "$TTL" {
+				ttl = false
+			}
+		case RecordEntry, UnknownEntry:
+			// The first record stating them becomes the one the rest
+			// inherit from.
+			if owner && e.blankOwner() {
+				e.insertToken(0, 0, target.Record.Name)
+			}
+			if e.Kind != RecordEntry {
+				continue
+			}
+			if ttl && !e.Record.ttlSet {
+				if e.raw != "" {
+					next := 1
+					if e.blankOwner() {
+						next = 0
+					}
+					e.insertToken(next, e.tokens[next].off, strconv.Itoa(target.Record.TTL))
+				}
+				e.Record.ttlSet = true
+			}
+			owner, ttl = false, false
+		}
+	}
+}
+
+func (zf *ZoneFile) SOA() *Entry {
+	for _, e := range zf.Entries {
+		if e.Kind == RecordEntry && e.Record.Type == "SOA" {
+			return e
+		}
+	}
+	return nil
+}
+
+func (zf *ZoneFile) schemeEntry() *Entry {
+	for _, e := range zf.Entries {
+		if e.Kind == CommentEntry && schemeCommentRegex.MatchString(e.Comment) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (zf *ZoneFile) SerialScheme() serial.Scheme {
+	if e := zf.schemeEntry(); e != nil {
+		m := schemeCommentRegex.FindStringSubmatch(e.Comment)
+		if scheme, err := serial.ParseScheme(m[1]); err == nil {
+			return scheme
+		}
+	}
+	return serial.DefaultScheme
+}
+
+func (zf *ZoneFile) SetSerialScheme(scheme serial.Scheme) {
+	comment := fmt.Sprintf("; b9m: serial-scheme=%s", scheme)
+	if e := zf.schemeEntry(); e != nil {
+		e.Comment = comment
+		e.raw = ""
+		e.tokens = nil
+		return
+	}
+	e := &Entry{Kind: CommentEntry, Comment: comment}
+	zf.Entries = append([]*Entry{e}, zf.Entries...)
+}
+
+// BumpSerial advances the SOA serial according to the zone's serial scheme.
+func (zf *ZoneFile) BumpSerial(now time.Time) (uint32, error) {
+	e := zf.SOA()
+	if e == nil {
+		return 0, fmt.Errorf("SOA record not found")
+	}
+	soa := e.Record.Value.(SOARecord)
+	next := serial.Next(zf.SerialScheme(), uint32(soa.Serial), now)
+	soa.Serial = int(next)
+	e.Record.Value = soa
+	if idx := e.serialToken(); idx >= 0 {
+		e.setToken(idx, strconv.FormatUint(uint64(next), 10))
+		var rdata []string
+		for _, t := range e.tokens[idx-2:] {
+			rdata = append(rdata, t.text)
+		}
+		e.Record.RData = strings.Join(rdata, " ")
+		return next, nil
+	}
+	e.Record.RData = fmt.Sprintf("%s %s %d %d %d %d %d", soa.MName, soa.RName, soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum)
+	e.raw = ""
+	return next, nil
+}
+
+func (e *Entry) serialToken() int {
+	for i, t := range e.tokens {
+		if strings.EqualFold(t.text, "SOA") && i+3 < len(e.tokens) {
+			return i + 3
+		}
+	}
+	return -1
+}
+
+func Fqdn(name, origin string) string {
+	if name == "@" {
+		return origin
+	}
+	if isAbsolute(name) {
+		return name
+	}
+	return name + "." + origin
+}
@@ -0,0 +1,276 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordsOf renders the records of zf one per line as "name ttl type rdata".
+func recordsOf(zf *ZoneFile) string {
+	var lines []string
+	for _, rec := range zf.Data().Records {
+		lines = append(lines, fmt.Sprintf("%s %d %s %s", rec.Name, rec.TTL, rec.Type, rec.RData))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestZoneRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"$TTL 86400\n@ IN SOA ns1.example.com. admin.example.com. 1 2 3 4 5\n",
+		"; header\n\n$ORIGIN example.com.\n@\tIN\tNS\tns1 ; trailing\n\n\n",
+		"@ IN SOA ns1 admin (\n\t2024010100 ; serial\n\t3600 900 604800 300 )\n",
+		"www 300 IN A 192.0.2.1\n    IN AAAA 2001:db8::1\n",
+		"txt IN TXT \"semi;colon\" \"(paren)\" \"esc\\\"aped\"\n",
+		"odd IN FOO some data\nbroken IN A 1.2.3\n",
+		"no-newline IN A 192.0.2.2",
+		"crlf IN A 192.0.2.3\r\n",
+		"$GENERATE 1-4 host$ A 192.0.2.$\n",
+	}
+	for _, data := range tests {
+		zf := ParseZone([]byte(data), "example.com")
+		if got := string(zf.Bytes()); got != data {
+			t.Errorf("round trip of %q gave %q", data, got)
+		}
+	}
+}
+
+func TestZoneInheritance(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+		want string
+	}{
+		{"owner", "www 300 IN A 192.0.2.1\n  IN AAAA 2001:db8::1\n\tTXT \"x\"\n",
+			"www.example.com. 300 A 192.0.2.1\nwww.example.com. 300 AAAA 2001:db8::1\nwww.example.com. 300 TXT \"x\""},
+		{"owner across comments", "www 300 A 192.0.2.1\n; comment\n\n  A 192.0.2.2\n",
+			"www.example.com. 300 A 192.0.2.1\nwww.example.com. 300 A 192.0.2.2"},
+		{"TTL and class in either order", "a IN 60 A 192.0.2.1\nb 60 IN A 192.0.2.2\nc 1h A 192.0.2.3\n",
+			"a.example.com. 60 A 192.0.2.1\nb.example.com. 60 A 192.0.2.2\nc.example.com. 3600 A 192.0.2.3"},
+		{"last explicit TTL", "@ 3600 IN SOA ns1 admin 1 2 3 4 5\n@ IN NS ns1\nwww 60 A 192.0.2.1\nmail A 192.0.2.2\n",
+			"example.com. 3600 SOA ns1 admin 1 2 3 4 5\nexample.com. 3600 NS ns1\nwww.example.com. 60 A 192.0.2.1\nmail.example.com. 60 A 192.0.2.2"},
+		{"$TTL wins over last explicit TTL", "$TTL 100\nwww 60 A 192.0.2.1\nmail A 192.0.2.2\n",
+			"www.example.com. 60 A 192.0.2.1\nmail.example.com. 100 A 192.0.2.2"},
+		{"SOA minimum without $TTL", "@ IN SOA a. b. 1 2 3 4 3600\nwww IN A 192.0.2.1\n",
+			"example.com. 3600 SOA a. b. 1 2 3 4 3600\nwww.example.com. 3600 A 192.0.2.1"},
+		{"explicit TTL after SOA minimum", "@ SOA a. b. 1 2 3 4 3600\nwww 60 A 192.0.2.1\nmail A 192.0.2.2\n",
+			"example.com. 3600 SOA a. b. 1 2 3 4 3600\nwww.example.com. 60 A 192.0.2.1\nmail.example.com. 60 A 192.0.2.2"},
+		{"$TTL over SOA minimum", "$TTL 60\n@ SOA a. b. 1 2 3 4 3600\nwww A 192.0.2.1\n",
+			"example.com. 60 SOA a. b. 1 2 3 4 3600\nwww.example.com. 60 A 192.0.2.1"},
+		{"explicit TTL before SOA", "www 60 A 192.0.2.1\n@ SOA a. b. 1 2 3 4 3600\n",
+			"www.example.com. 60 A 192.0.2.1\nexample.com. 60 SOA a. b. 1 2 3 4 3600"},
+		{"@ and absolute names", "@ 60 A 192.0.2.1\nhost.example.net. 60 A 192.0.2.2\n",
+			"example.com. 60 A 192.0.2.1\nhost.example.net. 60 A 192.0.2.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zf := ParseZone([]byte(tt.zone), "example.com")
+			if err := FirstError(zf.Diagnostics); err != nil {
+				t.Fatal(err)
+			}
+			if got := recordsOf(zf); got != tt.want {
+				t.Errorf("records:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZoneOriginScoping(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.zone": "$TTL 60\n" +
+			"@ A 192.0.2.1\n" +
+			"$INCLUDE sub.inc sub\n" +
+			"after A 192.0.2.2\n" +
+			"$ORIGIN child\n" +
+			"host A 192.0.2.3\n" +
+			"$INCLUDE plain.inc\n" +
+			"last A 192.0.2.4\n",
+		"sub.inc":   "@ A 192.0.2.10\n$ORIGIN deeper.sub.example.com.\n$TTL 30\nx A 192.0.2.11\n",
+		"plain.inc": "y A 192.0.2.20\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zf, err := loadZoneFile(filepath.Join(dir, "main.zone"), "example.com.", 0, &zoneLoader{dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := FirstError(zf.Diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"example.com. 60 A 192.0.2.1",
+		"sub.example.com. 60 A 192.0.2.10",
+		"x.deeper.sub.example.com. 30 A 192.0.2.11",
+		"after.example.com. 60 A 192.0.2.2",
+		"host.child.example.com. 60 A 192.0.2.3",
+		"y.child.example.com. 60 A 192.0.2.20",
+		"last.child.example.com. 60 A 192.0.2.4",
+	}, "\n")
+	if got := recordsOf(zf); got != want {
+		t.Errorf("records:\n%s\nwant:\n%s", got, want)
+	}
+	origins := []string{"example.com.", "sub.example.com.", "deeper.sub.example.com.", "example.com.",
+		"child.example.com.", "child.example.com.", "child.example.com."}
+	for i, rec := range zf.Data().Records {
+		if rec.Origin != origins[i] {
+			t.Errorf("%s has origin %s, want %s", rec.Name, rec.Origin, origins[i])
+		}
+	}
+	if got := len(zf.Files()); got != 3 {
+		t.Errorf("Files() has %d files, want 3", got)
+	}
+}
+
+func TestIncludeLoop(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "loop.zone")
+	if err := os.WriteFile(path, []byte("$INCLUDE loop.zone\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zf, err := loadZoneFile(path, "example.com.", 0, &zoneLoader{dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := FirstError(zf.Diagnostics); err == nil || !strings.Contains(err.Error(), "$INCLUDE loop") {
+		t.Errorf("FirstError() = %v, want an $INCLUDE loop error", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"$GENERATE 1-3 host$ A 192.0.2.$",
+			"host1.example.com. 0 A 192.0.2.1\nhost2.example.com. 0 A 192.0.2.2\nhost3.example.com. 0 A 192.0.2.3"},
+		{"$GENERATE 0-4/2 h$ 300 IN A 192.0.2.$",
+			"h0.example.com. 300 A 192.0.2.0\nh2.example.com. 300 A 192.0.2.2\nh4.example.com. 300 A 192.0.2.4"},
+		{"$GENERATE 1-2 ${10,3,d} CNAME x${0,2,x}",
+			"011.example.com. 0 CNAME x01\n012.example.com. 0 CNAME x02"},
+		{"$GENERATE 254-255 ${0,0,X} PTR ${0,0,o}.example.net.",
+			"FE.example.com. 0 PTR 376.example.net.\nFF.example.com. 0 PTR 377.example.net."},
+		{"$GENERATE 18-18 ${0,7,n} PTR host.",
+			"2.1.0.0.example.com. 0 PTR host."},
+		{"$GENERATE 171-171 ${0,0,N} PTR host.",
+			"B.A.example.com. 0 PTR host."},
+		{"$GENERATE 1-1 \\$$ TXT x",
+			"$1.example.com. 0 TXT x"},
+		{"$GENERATE 2147483645-2147483647/2 h$ TXT x",
+			"h2147483645.example.com. 0 TXT x\nh2147483647.example.com. 0 TXT x"},
+	}
+	for _, tt := range tests {
+		zf := ParseZone([]byte(tt.line+"\n"), "example.com")
+		if err := FirstError(zf.Diagnostics); err != nil {
+			t.Errorf("%s: %v", tt.line, err)
+			continue
+		}
+		if got := recordsOf(zf); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.line, got, tt.want)
+		}
+		for _, rec := range zf.Data().Records {
+			if rec.Directive != "$GENERATE" {
+				t.Errorf("%s: record %s not marked as generated", tt.line, rec.Name)
+			}
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"$GENERATE 0-1 host${-5,0,n} A 192.0.2.$", "negative value"},
+		{"$GENERATE 0-1 host${-1} A 192.0.2.$", "negative value"},
+		{"$GENERATE 0-100000 host$ A 192.0.2.1", "more than"},
+		{"$GENERATE 0-1 host${0,1000} A 192.0.2.1", "invalid width"},
+		{"$GENERATE 5-1 host$ A 192.0.2.1", "invalid $GENERATE range"},
+		{"$GENERATE 9223372036854775806-9223372036854775807 h$ A 1.2.3.4", "invalid $GENERATE range"},
+		{"$GENERATE 2147483647-2147483648 h$ A 1.2.3.4", "invalid $GENERATE range"},
+		{"$GENERATE 1-5/0 host$ A 192.0.2.1", "invalid $GENERATE step"},
+		{"$GENERATE 1-2 host${0,0,z} A 192.0.2.1", "invalid base"},
+		{"$GENERATE 1-2 host${0 A 192.0.2.1", "unterminated modifier"},
+	}
+	for _, tt := range tests {
+		zf := ParseZone([]byte(tt.line+"\n"), "example.com")
+		if err := FirstError(zf.Diagnostics); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.line, err, tt.want)
+		}
+	}
+}
+
+func TestRemoveKeepsInheritedOwnerAndTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		zone   string
+		remove int
+		want   string
+	}{
+		{"blank owner after removed record",
+			"@ 3600 SOA ns1 admin 1 2 3 4 5\nwww 600 IN A 192.0.2.1\n    IN AAAA 2001:db8::1 ; v6\nmail A 192.0.2.2\n", 1,
+			"@ 3600 SOA ns1 admin 1 2 3 4 5\nwww.example.com.    600 IN AAAA 2001:db8::1 ; v6\nmail A 192.0.2.2\n"},
+		{"explicit owner after removed record",
+			"www 600 IN A 192.0.2.1\nmail 600 A 192.0.2.2\n", 0,
+			"mail 600 A 192.0.2.2\n"},
+		{"TTL kept by $TTL",
+			"$TTL 60\nwww 600 IN A 192.0.2.1\nmail A 192.0.2.2\n", 0,
+			"$TTL 60\nmail A 192.0.2.2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zf := ParseZone([]byte(tt.zone), "example.com")
+			before := zf.Data().Records
+			removed := zf.Records()[tt.remove]
+			if !zf.Remove(removed) {
+				t.Fatal("Remove() = false")
+			}
+			if got := string(zf.Bytes()); got != tt.want {
+				t.Errorf("zone after Remove():\n%s\nwant:\n%s", got, tt.want)
+			}
+			after := ParseZone(zf.Bytes(), "example.com").Data().Records
+			before = append(before[:tt.remove], before[tt.remove+1:]...)
+			for i := range after {
+				if after[i].Name != before[i].Name || after[i].TTL != before[i].TTL {
+					t.Errorf("record %d is %s %d after Remove(), want %s %d", i, after[i].Name, after[i].TTL, before[i].Name, before[i].TTL)
+				}
+			}
+		})
+	}
+}
+
+func TestReplaceAndSetRData(t *testing.T) {
+	zf := ParseZone([]byte("@ 3600 IN SOA ns1 admin 1 2 3 4 5\n@ IN NS ns1\nwww 600 A 192.0.2.1\n  AAAA 2001:db8::1\n"), "example.com")
+	recs := zf.Records()
+	if err := recs[1].SetRData("ns2.example.net."); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := NewRecord("www.example.com.", 300, "A", "192.0.2.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf.Replace(recs[2], rec)
+	if got := zf.Records()[2].Record.Origin; got != "example.com." {
+		t.Errorf("replaced record has origin %q, want the origin of the record it replaces", got)
+	}
+	want := "@ 3600 IN SOA ns1 admin 1 2 3 4 5\n" +
+		"example.com. IN NS ns2.example.net.\n" +
+		"www.example.com. 300 IN A 192.0.2.9\n" +
+		"  600 AAAA 2001:db8::1\n"
+	if got := string(zf.Bytes()); got != want {
+		t.Errorf("zone:\n%s\nwant:\n%s", got, want)
+	}
+	wantRecords := "example.com. 3600 SOA ns1 admin 1 2 3 4 5\n" +
+		"example.com. 3600 NS ns2.example.net.\n" +
+		"www.example.com. 300 A 192.0.2.9\n" +
+		"www.example.com. 600 AAAA 2001:db8::1"
+	if got := recordsOf(ParseZone(zf.Bytes(), "example.com")); got != wantRecords {
+		t.Errorf("records:\n%s\nwant:\n%s", got, wantRecords)
+	}
+}
@@ -0,0 +1,128 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/parser"
+)
+
+// errUnchanged is returned by a modifyZone edit that left the zone as it
+// was, so nothing is written.
+var errUnchanged = errors.New("zone unchanged")
+
+// errNoReverseZone is returned by lookupPTR for addresses that no primary
+// reverse zone of the view covers.
+var errNoReverseZone = errors.New("no primary reverse zone covers")
+
+// reversePTR is the PTR record mirroring an A or AAAA record.
+type reversePTR struct {
+	zone, owner, target string
+}
+
+// lookupPTR returns the PTR record for the A or AAAA record of fqdn with the
+// given address, placed in the primary reverse zone of view that most
+// closely covers the address.
+func lookupPTR(inst *config.Instance, view, fqdn string, rType RecordType, value string) (*reversePTR, error) {
+	if rType != A && rType != AAAA {
+		return nil, fmt.Errorf("PTR records can only be synchronized for A and AAAA records, not %s", rType)
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address format: %s", value)
+	}
+	domains, err := parser.GetDomains(inst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find reverse zone for %s: %w", ip, err)
+	}
+	var ptr *reversePTR
+	for _, d := range domains {
+		if d.View != view || !d.IsPrimary() || (ptr != nil && len(d.Name) <= len(ptr.zone)) {
+			continue
+		}
+		if owner, ok := parser.ReverseOwner(ip, d.Name); ok {
+			ptr = &reversePTR{zone: d.Name, owner: owner, target: strings.TrimSuffix(fqdn, ".") + "."}
+		}
+	}
+	if ptr == nil {
+		return nil, fmt.Errorf("%w %s", errNoReverseZone, ip)
+	}
+	return ptr, nil
+}
+
+// recordPTR returns the PTR record for rec, an A or AAAA record of domain,
+// with its address set to value, or nil if no reverse zone covers the
+// address.
+func recordPTR(inst *config.Instance, view, domain string, rec *parser.ZoneRecord, value string) (*reversePTR, error) {
+	ptr, err := lookupPTR(inst, view, parser.Fqdn(rec.Name, domain+"."), RecordType(rec.Type), value)
+	if errors.Is(err, errNoReverseZone) {
+		return nil, nil
+	}
+	return ptr, err
+}
+
+// replacePTR replaces the PTR record old with new, either of which may be nil,
+// as part of tx.
+func replacePTR(tx *ZoneTx, old, new *reversePTR, ttl int) error {
+	if old != nil && (new == nil || old.zone != new.zone) {
+		if err := editPTR(tx, old.zone, old, nil, 0); err != nil {
+			return err
+		}
+		old = nil
+	}
+	if new != nil {
+		return editPTR(tx, new.zone, old, new, ttl)
+	}
+	return nil
+}
+
+func editPTR(tx *ZoneTx, zone string, remove, add *reversePTR, ttl int) error {
+	return tx.Edit(zone, "synchronize PTR record in", func(zf *parser.ZoneFile) error {
+		changed := false
+		if remove != nil {
+			if e := findRecord(zf, zone, remove.owner, PTR, remove.target); e != nil {
+				removeRecords(zf, zone, recordID(zone, *e.Record))
+				changed = true
+			}
+		}
+		if add != nil && findRecord(zf, zone, add.owner, PTR, add.target) == nil {
+			rec, err := parser.NewRecord(parser.Fqdn(add.owner, zone+"."), ttl, string(PTR), add.target)
+			if err != nil {
+				return fmt.Errorf("invalid PTR record for %s: %w", add.target, err)
+			}
+			zf.AppendRecord(rec)
+			changed = true
+		}
+		if !changed {
+			return errUnchanged
+		}
+		return nil
+	})
+}
+
+// AddClasslessDelegation adds to tx the records delegating the RFC 2317
+// classless reverse zone to its parent, which must be a primary zone of the
+// view of tx.
+func AddClasslessDelegation(tx *ZoneTx, zone string, nameservers []string) error {
+	records, err := parser.ClasslessDelegation(zone, nameservers)
+	if err != nil {
+		return err
+	}
+	_, parent, _ := parser.ClasslessZone(zone)
+	return tx.Edit(parent, "add classless delegation to", func(zf *parser.ZoneFile) error {
+		changed := false
+		for _, rec := range records {
+			if findRecord(zf, parent, rec.Name, RecordType(rec.Type), rec.RData) == nil {
+				zf.AppendRecord(rec)
+				changed = true
+			}
+		}
+		if !changed {
+			return errUnchanged
+		}
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package record
+
+import (
+	"strings"
+	"testing"
+)
+
+const testReverseZone = "$TTL 3600\n" +
+	"@ IN SOA ns1.example.com. admin.example.com. 1 7200 3600 1209600 3600\n" +
+	"@ IN NS ns1.example.com.\n"
+
+func TestSyncPTR(t *testing.T) {
+	inst := newTestInstance(t, primaryZones("example.com", "2.0.192.in-addr.arpa"), map[string]string{
+		"example.com":          testZone,
+		"2.0.192.in-addr.arpa": testReverseZone,
+	})
+	hasPTR := func() bool {
+		return strings.Contains(readZone(t, inst, "2.0.192.in-addr.arpa"), "PTR mail.example.com.")
+	}
+
+	if _, err := AddRecord(inst, "", "example.com", A, "mail", "192.0.2.20", 300, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readZone(t, inst, "2.0.192.in-addr.arpa"); !strings.HasSuffix(got, "\n20.2.0.192.in-addr.arpa. 300 IN PTR mail.example.com.\n") {
+		t.Fatalf("reverse zone after AddRecord():\n%s", got)
+	}
+
+	// Moving the address out of every reverse zone removes the PTR record.
+	if _, err := UpdateRecord(inst, "", "example.com", "mail", A, "192.0.2.20", "198.51.100.20", 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if hasPTR() {
+		t.Error("PTR record kept after the address left the reverse zone")
+	}
+
+	// Moving it back adds the PTR record again.
+	if _, err := UpdateRecord(inst, "", "example.com", "mail", A, "198.51.100.20", "192.0.2.21", 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if !hasPTR() || !strings.Contains(readZone(t, inst, "2.0.192.in-addr.arpa"), "21.2.0.192.in-addr.arpa.") {
+		t.Error("PTR record not added for an address entering the reverse zone")
+	}
+
+	if err := DeleteRecord(inst, "", "example.com", "mail", A, "192.0.2.21", true); err != nil {
+		t.Fatal(err)
+	}
+	if hasPTR() {
+		t.Error("PTR record kept after DeleteRecord()")
+	}
+
+	// Records outside every reverse zone have no PTR record to sync.
+	if _, err := AddRecord(inst, "", "example.com", A, "web", "198.51.100.1", 300, true); err == nil {
+		t.Error("AddRecord() with PTR sync succeeded without a reverse zone")
+	}
+	if _, err := AddRecord(inst, "", "example.com", A, "web", "198.51.100.1", 300, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteRecord(inst, "", "example.com", "web", A, "198.51.100.1", true); err != nil {
+		t.Errorf("DeleteRecord() of a record with an uncovered address: %v", err)
+	}
+}
+
+func TestSyncPTRAtomic(t *testing.T) {
+	inst := newTestInstance(t, primaryZones("example.com", "2.0.192.in-addr.arpa"), map[string]string{
+		"example.com":          testZone,
+		"2.0.192.in-addr.arpa": testReverseZone + "x IN A 1.2.3\n",
+	})
+	if _, err := AddRecord(inst, "", "example.com", A, "mail", "192.0.2.20", 300, true); err == nil {
+		t.Fatal("AddRecord() succeeded with a broken reverse zone")
+	}
+	if got := readZone(t, inst, "example.com"); got != testZone {
+		t.Errorf("forward zone changed although its PTR record could not be added:\n%s", got)
+	}
+}
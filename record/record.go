@@ -1,23 +1,32 @@
 package record
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"os"
-	"regexp"
 	"slices"
 	"strings"
 
+	"github.com/AfazTech/b9m/config"
 	"github.com/AfazTech/b9m/parser"
-	"github.com/AfazTech/b9m/servicemanager"
 	"github.com/AfazTech/b9m/utils"
 )
 
+// DNSRecord is a record as returned to callers. Value holds the rdata in
+// zone file format; Data holds the same rdata as typed fields for record
+// types that have more than one field (parser.MXData, parser.SOARecord, ...).
 type DNSRecord struct {
-	Name  string
-	TTL   int
-	Type  RecordType
-	Value string
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	TTL   int         `json:"ttl"`
+	Type  RecordType  `json:"type"`
+	Value string      `json:"value"`
+	Data  interface{} `json:"data,omitempty"`
+	// Directive is set for records produced by $GENERATE or $INCLUDE,
+	// which are read-only.
+	Directive string `json:"directive,omitempty"`
 }
 
 type RecordType string
@@ -30,153 +39,382 @@ const (
 	MX    RecordType = "MX"
 	NS    RecordType = "NS"
 	PTR   RecordType = "PTR"
+	SRV   RecordType = "SRV"
+	SOA   RecordType = "SOA"
+	CAA   RecordType = "CAA"
+	TLSA  RecordType = "TLSA"
+	SSHFP RecordType = "SSHFP"
+	HTTPS RecordType = "HTTPS"
+	SVCB  RecordType = "SVCB"
+	NAPTR RecordType = "NAPTR"
+	DS    RecordType = "DS"
+	LOC   RecordType = "LOC"
 )
 
-func AddRecord(domain string, recordType RecordType, sub, value string, ttl int) error {
-	if err := utils.ValidateDomain(domain); err != nil {
-		return fmt.Errorf("failed to add record to domain %s: %w", domain, err)
+var validRecordTypes = []RecordType{A, AAAA, CNAME, TXT, MX, NS, PTR, SRV, CAA, TLSA, SSHFP, HTTPS, SVCB, NAPTR, DS, LOC}
+
+// FormatData turns the structured fields of a record, as accepted by the
+// API, into rdata in zone file format.
+func FormatData(rType RecordType, data []byte) (string, error) {
+	var v fmt.Stringer
+	switch rType {
+	case TXT:
+		v = &parser.TXTData{}
+	case MX:
+		v = &parser.MXData{}
+	case SRV:
+		v = &parser.SRVData{}
+	case CAA:
+		v = &parser.CAAData{}
+	case TLSA:
+		v = &parser.TLSAData{}
+	case SSHFP:
+		v = &parser.SSHFPData{}
+	case HTTPS, SVCB:
+		v = &parser.SVCBData{}
+	case NAPTR:
+		v = &parser.NAPTRData{}
+	case DS:
+		v = &parser.DSData{}
+	case LOC:
+		v = &parser.LOCData{}
+	default:
+		return "", fmt.Errorf("record type %s takes a plain value", rType)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return "", fmt.Errorf("invalid %s record data: %w", rType, err)
 	}
+	return v.String(), nil
+}
+
+// AddRecord adds a record to domain and returns it. With syncPTR, an A or
+// AAAA record also gets a PTR record in the reverse zone covering its address.
+func AddRecord(inst *config.Instance, view, domain string, recordType RecordType, sub, value string, ttl int, syncPTR bool) (DNSRecord, error) {
 	if err := utils.ValidateSubdomain(sub); err != nil {
-		return fmt.Errorf("failed to add record to domain %s, invalid subdomain %s: %w", domain, sub, err)
+		return DNSRecord{}, fmt.Errorf("failed to add record to domain %s, invalid subdomain %s: %w", domain, sub, err)
 	}
-	exists, err := utils.DomainExists(domain)
-	if err != nil {
-		return fmt.Errorf("error checking existence of domain %s: %w", domain, err)
+	if ttl <= 0 {
+		return DNSRecord{}, fmt.Errorf("invalid TTL %d for domain %s: TTL must be greater than 0", ttl, domain)
 	}
-	if !exists {
-		return fmt.Errorf("domain does not exist: %s", domain)
+	if recordType == TXT {
+		value = parser.FormatTXT(value)
 	}
-	if ttl <= 0 {
-		return fmt.Errorf("invalid TTL %d for domain %s: TTL must be greater than 0", ttl, domain)
+	if err := validateValue(domain, recordType, value); err != nil {
+		return DNSRecord{}, err
 	}
-	validRecordTypes := []RecordType{A, AAAA, CNAME, TXT, MX, NS, PTR}
-	if !slices.Contains(validRecordTypes, recordType) {
-		return fmt.Errorf("invalid record type %s for domain %s", recordType, domain)
+
+	// The record is appended after any $ORIGIN, so its owner is written
+	// out in full.
+	rec, err := parser.NewRecord(parser.Fqdn(sub, domain+"."), ttl, string(recordType), value)
+	if err != nil {
+		return DNSRecord{}, fmt.Errorf("invalid %s record value %q for domain %s: %w", recordType, value, domain, err)
 	}
-	if recordType == A || recordType == AAAA {
-		if err := utils.ValidateIP(value); err != nil {
-			return fmt.Errorf("invalid IP address for record in domain %s: %w", domain, err)
+	var ptr *reversePTR
+	if syncPTR {
+		if ptr, err = lookupPTR(inst, view, parser.Fqdn(sub, domain+"."), recordType, value); err != nil {
+			return DNSRecord{}, fmt.Errorf("failed to add record to domain %s: %w", domain, err)
 		}
 	}
-	domains, err := parser.GetDomains()
+
+	tx := NewZoneTx(inst, view)
+	defer tx.Unlock()
+	err = tx.Edit(domain, "add record to", func(zf *parser.ZoneFile) error {
+		zf.AppendRecord(rec)
+		return nil
+	})
+	if err == nil && ptr != nil {
+		err = replacePTR(tx, nil, ptr, ttl)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to retrieve domains for adding record to %s: %w", domain, err)
+		return DNSRecord{}, tx.Abort(err)
 	}
-	zoneFile, exists := domains[domain]
-	if !exists {
-		return fmt.Errorf("zone file not found for domain: %s", domain)
+	if err := tx.Commit(); err != nil {
+		return DNSRecord{}, err
 	}
+	return newDNSRecord(domain, rec), nil
+}
 
-	rec := DNSRecord{
-		Name:  sub,
-		TTL:   ttl,
-		Type:  recordType,
-		Value: value,
-	}
-	var fullName string
-	if rec.Name == "@" {
-		fullName = rec.Name
-	} else {
-		fullName = rec.Name + "." + domain + "."
+func DeleteRecord(inst *config.Instance, view, domain, sub string, rType RecordType, value string, syncPTR bool) error {
+	if err := utils.ValidateSubdomain(sub); err != nil {
+		return fmt.Errorf("failed to delete record from domain %s, invalid subdomain %s: %w", domain, sub, err)
 	}
+	return deleteRecord(inst, view, domain, syncPTR, func(zf *parser.ZoneFile) (*parser.Entry, error) {
+		e := findRecord(zf, domain, sub, rType, value)
+		if e == nil {
+			return nil, fmt.Errorf("record not found or could not be deleted: %s.%s IN %s %s", sub, domain, rType, value)
+		}
+		return e, nil
+	})
+}
 
-	recordLine := fmt.Sprintf("%s %d IN %s %s\n", fullName, rec.TTL, rec.Type, rec.Value)
+func DeleteRecordByID(inst *config.Instance, view, domain, id string, syncPTR bool) error {
+	return deleteRecord(inst, view, domain, syncPTR, func(zf *parser.ZoneFile) (*parser.Entry, error) {
+		e := findRecordByID(zf, domain, id)
+		if e == nil {
+			return nil, recordNotFound(zf, domain, id)
+		}
+		return e, nil
+	})
+}
 
-	f, err := os.OpenFile(zoneFile, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+func deleteRecord(inst *config.Instance, view, domain string, syncPTR bool, find func(zf *parser.ZoneFile) (*parser.Entry, error)) error {
+	var ptr *reversePTR
+	tx := NewZoneTx(inst, view)
+	defer tx.Unlock()
+	err := tx.Edit(domain, "delete record from", func(zf *parser.ZoneFile) error {
+		e, err := find(zf)
+		if err != nil {
+			return err
+		}
+		if syncPTR {
+			if ptr, err = recordPTR(inst, view, domain, e.Record, e.Record.RData); err != nil {
+				return fmt.Errorf("failed to delete record from domain %s: %w", domain, err)
+			}
+		}
+		removeRecords(zf, domain, recordID(domain, *e.Record))
+		return nil
+	})
+	if err == nil && ptr != nil {
+		err = replacePTR(tx, ptr, nil, 0)
 	}
-	defer f.Close()
-
-	_, err = f.WriteString(recordLine)
 	if err != nil {
-		return fmt.Errorf("failed to add record to domain %s: %w", domain, err)
+		return tx.Abort(err)
 	}
-	return servicemanager.ReloadBind()
+	return tx.Commit()
 }
-func DeleteRecord(domain, sub string, rType RecordType, value string) error {
-	if err := utils.ValidateDomain(domain); err != nil {
-		return fmt.Errorf("failed to delete record from domain %s: %w", domain, err)
-	}
+
+// UpdateRecord replaces the value and, unless ttl is 0, the TTL of a record
+// and returns the updated record, whose ID changes with its value.
+func UpdateRecord(inst *config.Instance, view, domain, sub string, rType RecordType, value, newValue string, ttl int, syncPTR bool) (DNSRecord, error) {
 	if err := utils.ValidateSubdomain(sub); err != nil {
-		return fmt.Errorf("failed to delete record from domain %s, invalid subdomain %s: %w", domain, sub, err)
+		return DNSRecord{}, fmt.Errorf("failed to update record in domain %s, invalid subdomain %s: %w", domain, sub, err)
 	}
+	return updateRecord(inst, view, domain, newValue, ttl, syncPTR, func(zf *parser.ZoneFile) (*parser.Entry, error) {
+		e := findRecord(zf, domain, sub, rType, value)
+		if e == nil {
+			return nil, fmt.Errorf("record not found: %s.%s IN %s %s", sub, domain, rType, value)
+		}
+		return e, nil
+	})
+}
 
-	exists, err := utils.DomainExists(domain)
+func UpdateRecordByID(inst *config.Instance, view, domain, id, newValue string, ttl int, syncPTR bool) (DNSRecord, error) {
+	return updateRecord(inst, view, domain, newValue, ttl, syncPTR, func(zf *parser.ZoneFile) (*parser.Entry, error) {
+		e := findRecordByID(zf, domain, id)
+		if e == nil {
+			return nil, recordNotFound(zf, domain, id)
+		}
+		return e, nil
+	})
+}
+
+func updateRecord(inst *config.Instance, view, domain, newValue string, ttl int, syncPTR bool, find func(zf *parser.ZoneFile) (*parser.Entry, error)) (DNSRecord, error) {
+	if ttl < 0 {
+		return DNSRecord{}, fmt.Errorf("invalid TTL %d for domain %s: TTL must be greater than 0", ttl, domain)
+	}
+	var updated parser.ZoneRecord
+	var oldPTR, newPTR *reversePTR
+	tx := NewZoneTx(inst, view)
+	defer tx.Unlock()
+	err := tx.Edit(domain, "update record in", func(zf *parser.ZoneFile) error {
+		e, err := find(zf)
+		if err != nil {
+			return err
+		}
+		rType := RecordType(e.Record.Type)
+		if newValue == "" {
+			newValue = e.Record.RData
+		} else {
+			if rType == TXT {
+				newValue = parser.FormatTXT(newValue)
+			}
+			if err := validateValue(domain, rType, newValue); err != nil {
+				return err
+			}
+		}
+		if syncPTR {
+			if oldPTR, err = recordPTR(inst, view, domain, e.Record, e.Record.RData); err != nil {
+				return fmt.Errorf("failed to update record in domain %s: %w", domain, err)
+			}
+			if newPTR, err = recordPTR(inst, view, domain, e.Record, newValue); err != nil {
+				return fmt.Errorf("failed to update record in domain %s: %w", domain, err)
+			}
+		}
+		// Without a new TTL the entry keeps its TTL as written, or none if
+		// it inherits it.
+		if ttl == 0 {
+			ttl = e.Record.TTL
+			if err := e.SetRData(newValue); err != nil {
+				return fmt.Errorf("invalid %s record value %q for domain %s: %w", rType, newValue, domain, err)
+			}
+			updated = *e.Record
+			return nil
+		}
+		rec, err := parser.NewRecord(e.Record.Name, ttl, e.Record.Type, newValue)
+		if err != nil {
+			return fmt.Errorf("invalid %s record value %q for domain %s: %w", rType, newValue, domain, err)
+		}
+		rec.Class = e.Record.Class
+		zf.Replace(e, rec)
+		updated = rec
+		return nil
+	})
+	if err == nil {
+		err = replacePTR(tx, oldPTR, newPTR, ttl)
+	}
 	if err != nil {
-		return fmt.Errorf("error checking existence of domain %s: %w", domain, err)
+		return DNSRecord{}, tx.Abort(err)
 	}
-	if !exists {
-		return fmt.Errorf("domain does not exist: %s", domain)
+	if err := tx.Commit(); err != nil {
+		return DNSRecord{}, err
 	}
+	return newDNSRecord(domain, updated), nil
+}
 
-	domains, err := parser.GetDomains()
-	if err != nil {
-		return fmt.Errorf("failed to retrieve domains for deleting record from %s: %w", domain, err)
+// SetSOA changes the SOA contact and timers and the default TTL of domain.
+func SetSOA(inst *config.Instance, view, domain string, opts parser.SOAOptions) error {
+	if opts == (parser.SOAOptions{}) {
+		return fmt.Errorf("failed to update SOA of domain %s: nothing to change", domain)
+	}
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("failed to update SOA of domain %s: %w", domain, err)
 	}
+	return modifyZone(inst, view, domain, "update SOA of", func(zf *parser.ZoneFile) error {
+		if err := zf.SetSOA(opts); err != nil {
+			return fmt.Errorf("failed to update SOA of domain %s: %w", domain, err)
+		}
+		return nil
+	})
+}
 
-	zoneFile, exists := domains[domain]
-	if !exists {
-		return fmt.Errorf("zone file not found for domain: %s", domain)
+// recordID identifies a record by its owner, class, type and rdata, so it
+// stays the same across reloads and unrelated edits of the zone.
+func recordID(domain string, rec parser.ZoneRecord) string {
+	origin := strings.TrimSuffix(domain, ".") + "."
+	key := strings.Join([]string{
+		strings.ToLower(parser.Fqdn(rec.Name, origin)),
+		strings.ToUpper(rec.Class),
+		strings.ToUpper(rec.Type),
+		parser.NormalizeRData(rec.RData),
+	}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func findRecord(zf *parser.ZoneFile, domain, sub string, rType RecordType, value string) *parser.Entry {
+	origin := domain + "."
+	owner := parser.Fqdn(sub, origin)
+	rdata := parser.NormalizeRData(value)
+	if rType == TXT {
+		rdata = parser.FormatTXT(value)
+	}
+	for _, e := range zf.Records() {
+		if parser.Fqdn(e.Record.Name, origin) == owner && strings.EqualFold(e.Record.Type, string(rType)) && comparableRData(e.Record) == rdata {
+			return e
+		}
 	}
+	return nil
+}
 
-	data, err := os.ReadFile(zoneFile)
-	if err != nil {
-		return fmt.Errorf("failed to read zone file for domain %s: %w", domain, err)
+// comparableRData returns the rdata of rec in the form findRecord compares
+// against, so TXT records match regardless of how their strings were quoted.
+func comparableRData(rec *parser.ZoneRecord) string {
+	if txt, ok := rec.Value.(parser.TXTData); ok {
+		return txt.String()
 	}
+	return rec.RData
+}
 
-	pattern := fmt.Sprintf(`(?m)^\s*%s\.\s*(?:\d+\s+)?IN\s+%s\s+%s\s*$`,
-		regexp.QuoteMeta(sub+"."+domain), regexp.QuoteMeta(string(rType)), regexp.QuoteMeta(value))
-	re := regexp.MustCompile(pattern)
+func findRecordByID(zf *parser.ZoneFile, domain, id string) *parser.Entry {
+	for _, e := range zf.Records() {
+		if recordID(domain, *e.Record) == id {
+			return e
+		}
+	}
+	return nil
+}
 
-	newData := re.ReplaceAllString(string(data), "")
-	newData = strings.TrimSpace(newData) + "\n"
+func recordNotFound(zf *parser.ZoneFile, domain, id string) error {
+	for _, rec := range zf.Data().Records {
+		if rec.Directive != "" && recordID(domain, rec) == id {
+			return fmt.Errorf("record %s in domain %s comes from a %s directive and cannot be modified", id, domain, rec.Directive)
+		}
+	}
+	return fmt.Errorf("record %s not found in domain %s", id, domain)
+}
 
-	if string(data) == newData {
-		return fmt.Errorf("record not found or could not be deleted: %s.%s IN %s %s", sub, domain, rType, value)
+func removeRecords(zf *parser.ZoneFile, domain, id string) int {
+	removed := 0
+	for _, e := range zf.Records() {
+		if recordID(domain, *e.Record) == id {
+			zf.Remove(e)
+			removed++
+		}
 	}
+	return removed
+}
 
-	err = os.WriteFile(zoneFile, []byte(newData), 0666)
-	if err != nil {
-		return fmt.Errorf("failed to write updated zone file for domain %s: %w", domain, err)
+func validateValue(domain string, rType RecordType, value string) error {
+	if !slices.Contains(validRecordTypes, rType) {
+		return fmt.Errorf("invalid record type %s for domain %s", rType, domain)
+	}
+	if rType == A || rType == AAAA {
+		if err := utils.ValidateIP(value); err != nil {
+			return fmt.Errorf("invalid IP address for record in domain %s: %w", domain, err)
+		}
 	}
+	if err := utils.ValidateRData(string(rType), value); err != nil {
+		return fmt.Errorf("invalid record for domain %s: %w", domain, err)
+	}
+	return nil
+}
 
-	return servicemanager.ReloadBind()
+func loadZone(inst *config.Instance, view, domain string) (*parser.ZoneFile, error) {
+	zf, err := parser.LoadDomainZone(inst, view, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records of domain %s: %w", domain, err)
+	}
+	return zf, nil
 }
 
-func GetAllRecords(domain string) ([]DNSRecord, error) {
-	domains, err := parser.GetDomains()
+func GetAllRecords(inst *config.Instance, view, domain string) ([]DNSRecord, error) {
+	zf, err := loadZone(inst, view, domain)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve domains for getting records of %s: %w", domain, err)
+		return nil, err
 	}
-	zoneFile, ok := domains[domain]
-	if !ok {
-		return nil, fmt.Errorf("domain not found: %s", domain)
+	var records []DNSRecord
+	for _, rec := range zf.Data().Records {
+		records = append(records, newDNSRecord(domain, rec))
 	}
-	zoneData, err := parser.ParseZoneFile(zoneFile)
+	return records, nil
+}
+
+func GetRecord(inst *config.Instance, view, domain, id string) (DNSRecord, error) {
+	zf, err := loadZone(inst, view, domain)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse zone file %s for domain %s: %w", zoneFile, domain, err)
+		return DNSRecord{}, err
 	}
-	var records []DNSRecord
-	for _, rec := range zoneData.Records {
-		var valStr string
-		switch v := rec.Value.(type) {
-		case string:
-			valStr = v
-		default:
-			b, err := json.Marshal(v)
-			if err != nil {
-				valStr = fmt.Sprintf("%v", v)
-			} else {
-				valStr = string(b)
-			}
+	for _, rec := range zf.Data().Records {
+		if recordID(domain, rec) == id {
+			return newDNSRecord(domain, rec), nil
 		}
-		records = append(records, DNSRecord{
-			Name:  rec.Name,
-			TTL:   rec.TTL,
-			Type:  RecordType(rec.Type),
-			Value: valStr,
-		})
 	}
-	return records, nil
+	return DNSRecord{}, fmt.Errorf("record %s not found in domain %s", id, domain)
+}
+
+func newDNSRecord(domain string, rec parser.ZoneRecord) DNSRecord {
+	r := DNSRecord{
+		ID:        recordID(domain, rec),
+		Name:      rec.Name,
+		TTL:       rec.TTL,
+		Type:      RecordType(rec.Type),
+		Value:     rec.RData,
+		Directive: rec.Directive,
+	}
+	if _, ok := rec.Value.(string); !ok {
+		r.Data = rec.Value
+	}
+	return r
 }
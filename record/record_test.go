@@ -0,0 +1,191 @@
+package record
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/lock"
+	"github.com/AfazTech/b9m/parser"
+)
+
+const testZone = "$ORIGIN example.com.\n" +
+	"@ 3600 IN SOA ns1 admin 1 7200 3600 1209600 3600\n" +
+	"@ IN NS ns1\n" +
+	"ns1 IN A 192.0.2.1\n" +
+	"www 600 IN A 192.0.2.10\n" +
+	"    IN AAAA 2001:db8::10\n"
+
+// newTestInstance writes named.conf and the zone files, named by domain, to
+// a temporary zone directory and returns an instance managing them. Reloads
+// always succeed.
+func newTestInstance(t *testing.T, conf string, zones map[string]string) *config.Instance {
+	t.Helper()
+	dir := t.TempDir()
+	lock.SetDir(filepath.Join(dir, "locks"))
+	files := map[string]string{"named.conf": conf}
+	for domain, data := range zones {
+		files[domain+".db"] = data
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &config.Instance{Name: config.DefaultProfile, Profile: config.Profile{
+		ConfigFile: filepath.Join(dir, "named.conf"),
+		ZoneDir:    dir,
+		Rndc:       "true",
+	}}
+}
+
+func primaryZones(domains ...string) string {
+	var b strings.Builder
+	for _, d := range domains {
+		b.WriteString("zone \"" + d + "\" { type master; file \"" + d + ".db\"; };\n")
+	}
+	return b.String()
+}
+
+func readZone(t *testing.T, inst *config.Instance, domain string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(inst.ZoneDir, domain+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// zoneBody drops the SOA record, whose serial changes on every edit.
+func zoneBody(zone string) string {
+	var lines []string
+	for _, line := range strings.SplitAfter(zone, "\n") {
+		if !strings.Contains(line, " SOA ") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func TestUpdateRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		sub      string
+		rType    RecordType
+		value    string
+		newValue string
+		ttl      int
+		want     string
+	}{
+		{"value keeps TTL", "www", A, "192.0.2.10", "192.0.2.11", 0,
+			"www.example.com. 600 IN A 192.0.2.11\n    IN AAAA 2001:db8::10\n"},
+		{"inherited TTL stays inherited", "www", AAAA, "2001:db8::10", "2001:db8::11", 0,
+			"www 600 IN A 192.0.2.10\nwww.example.com. IN AAAA 2001:db8::11\n"},
+		{"new TTL keeps the TTL of the next record", "www", A, "192.0.2.10", "", 300,
+			"www.example.com. 300 IN A 192.0.2.10\n    600 IN AAAA 2001:db8::10\n"},
+		{"value and TTL", "ns1", A, "192.0.2.1", "192.0.2.2", 60,
+			"ns1.example.com. 60 IN A 192.0.2.2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newTestInstance(t, primaryZones("example.com"), map[string]string{"example.com": testZone})
+			before := readZone(t, inst, "example.com")
+			rec, err := UpdateRecord(inst, "", "example.com", tt.sub, tt.rType, tt.value, tt.newValue, tt.ttl, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := GetRecord(inst, "", "example.com", rec.ID); err != nil || !reflect.DeepEqual(got, rec) {
+				t.Errorf("GetRecord(%s) = %+v, %v, want %+v", rec.ID, got, err, rec)
+			}
+			after := readZone(t, inst, "example.com")
+			if after == before {
+				t.Fatal("zone file unchanged")
+			}
+			if got := zoneBody(after); !strings.Contains(got, tt.want) {
+				t.Errorf("zone:\n%s\nwant it to contain:\n%s", got, tt.want)
+			}
+			if strings.Contains(after, " 1 7200 ") {
+				t.Error("SOA serial not bumped")
+			}
+		})
+	}
+}
+
+func TestUpdateRecordErrors(t *testing.T) {
+	inst := newTestInstance(t, primaryZones("example.com"), map[string]string{"example.com": testZone})
+	for name, update := range map[string]func() error{
+		"missing record": func() error {
+			_, err := UpdateRecord(inst, "", "example.com", "mail", A, "192.0.2.10", "192.0.2.11", 0, false)
+			return err
+		},
+		"invalid value": func() error {
+			_, err := UpdateRecord(inst, "", "example.com", "www", A, "192.0.2.10", "2001:db8::1", 0, false)
+			return err
+		},
+		"missing ID": func() error {
+			_, err := UpdateRecordByID(inst, "", "example.com", "0123456789abcdef", "192.0.2.11", 0, false)
+			return err
+		},
+	} {
+		if err := update(); err == nil {
+			t.Errorf("%s: update succeeded", name)
+		}
+	}
+	if got := readZone(t, inst, "example.com"); got != testZone {
+		t.Errorf("zone changed by failed updates:\n%s", got)
+	}
+
+	inst.Rndc = "false"
+	if _, err := UpdateRecord(inst, "", "example.com", "www", A, "192.0.2.10", "192.0.2.11", 0, false); err == nil {
+		t.Error("update succeeded although the reload failed")
+	}
+	if got := readZone(t, inst, "example.com"); got != testZone {
+		t.Errorf("zone not restored after a failed reload:\n%s", got)
+	}
+}
+
+func TestRecordIDs(t *testing.T) {
+	inst := newTestInstance(t, primaryZones("example.com"), map[string]string{"example.com": testZone})
+	records, err := GetAllRecords(inst, "", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{}
+	for _, rec := range records {
+		if other, ok := ids[rec.ID]; ok {
+			t.Errorf("%s %s and %s share ID %s", rec.Name, rec.Type, other, rec.ID)
+		}
+		ids[rec.ID] = rec.Name + " " + string(rec.Type)
+	}
+
+	added, err := AddRecord(inst, "", "example.com", TXT, "txt", "hello world", 300, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetRecord(inst, "", "example.com", added.ID); err != nil || !reflect.DeepEqual(got, added) {
+		t.Errorf("GetRecord(%s) = %+v, %v, want the added record %+v", added.ID, got, err, added)
+	}
+	after, err := GetAllRecords(inst, "", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range after {
+		if rec.ID != added.ID && rec.Type != SOA && ids[rec.ID] == "" {
+			t.Errorf("ID of %s %s changed by an unrelated edit", rec.Name, rec.Type)
+		}
+	}
+
+	// The ID follows owner, type and rdata, not how they are written.
+	www := parser.ZoneRecord{Name: "www", Class: "IN", Type: "A", RData: "192.0.2.10"}
+	same := parser.ZoneRecord{Name: "WWW.example.com.", Class: "in", Type: "a", RData: "192.0.2.10", TTL: 60}
+	if recordID("example.com", www) != recordID("example.com.", same) {
+		t.Error("recordID() differs for the same record written differently")
+	}
+	www.RData = "192.0.2.11"
+	if recordID("example.com", www) == recordID("example.com", same) {
+		t.Error("recordID() is the same for different rdata")
+	}
+}
@@ -0,0 +1,115 @@
+package record
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AfazTech/b9m/checker"
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/lock"
+	"github.com/AfazTech/b9m/parser"
+	"github.com/AfazTech/b9m/servicemanager"
+	"github.com/AfazTech/b9m/utils"
+)
+
+// ZoneTx edits zones of one view in a single transaction, so that a change
+// spanning several zones, such as a record and its PTR record, is reloaded
+// once and rolled back as a whole. Zones are locked when first used and
+// stay locked until Unlock; a forward zone is locked before the reverse
+// zones holding its PTR records.
+type ZoneTx struct {
+	*utils.Transaction
+	inst    *config.Instance
+	view    string
+	locks   map[string]*lock.Lock
+	changed bool
+}
+
+func NewZoneTx(inst *config.Instance, view string) *ZoneTx {
+	return &ZoneTx{Transaction: utils.NewTransaction(), inst: inst, view: view, locks: map[string]*lock.Lock{}}
+}
+
+// Lock takes the zone lock of domain unless tx already holds it.
+func (tx *ZoneTx) Lock(domain string) error {
+	if _, ok := tx.locks[domain]; ok {
+		return nil
+	}
+	l, err := lock.Zone(tx.inst.Name, tx.view, domain)
+	if err != nil {
+		return err
+	}
+	tx.locks[domain] = l
+	return nil
+}
+
+func (tx *ZoneTx) Unlock() {
+	for _, l := range tx.locks {
+		l.Unlock()
+	}
+	tx.locks = map[string]*lock.Lock{}
+}
+
+// Edit runs edit on the zone of domain under its lock, then bumps the
+// serial, validates the result and writes it. An edit returning
+// errUnchanged leaves the zone as it is.
+func (tx *ZoneTx) Edit(domain, action string, edit func(zf *parser.ZoneFile) error) error {
+	if err := utils.ValidateDomain(domain); err != nil {
+		return fmt.Errorf("failed to %s domain %s: %w", action, domain, err)
+	}
+	if err := tx.Lock(domain); err != nil {
+		return err
+	}
+	zf, err := parser.LoadDomainZone(tx.inst, tx.view, domain)
+	if err != nil {
+		return fmt.Errorf("failed to %s domain %s: %w", action, domain, err)
+	}
+	if err := parser.FirstError(zf.Diagnostics); err != nil {
+		return fmt.Errorf("zone file for domain %s has errors: %w", domain, err)
+	}
+	if err := edit(zf); err == errUnchanged {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := zf.BumpSerial(time.Now()); err != nil {
+		return fmt.Errorf("failed to update SOA serial for domain %s: %w", domain, err)
+	}
+	if err := checker.CheckZone(domain, zf.Data()); err != nil {
+		return fmt.Errorf("refusing to %s domain %s: %w", action, domain, err)
+	}
+	if err := tx.WriteZone(zf); err != nil {
+		return fmt.Errorf("failed to %s domain %s: %w", action, domain, err)
+	}
+	tx.changed = true
+	return nil
+}
+
+// Commit reloads named if any zone was written, restoring the previous
+// files if named refuses them. Files written to the embedded transaction
+// directly must be reported with Changed.
+func (tx *ZoneTx) Commit() error {
+	if !tx.changed {
+		return nil
+	}
+	if err := servicemanager.ReloadBind(tx.inst); err != nil {
+		return tx.Abort(err)
+	}
+	return nil
+}
+
+// Changed marks tx as having written files, so that Commit reloads named.
+func (tx *ZoneTx) Changed() {
+	tx.changed = true
+}
+
+// modifyZone runs edit on the zone of domain in a transaction of its own
+// and reloads named with the result.
+func modifyZone(inst *config.Instance, view, domain, action string, edit func(zf *parser.ZoneFile) error) error {
+	tx := NewZoneTx(inst, view)
+	defer tx.Unlock()
+	if err := tx.Edit(domain, action, edit); err != nil {
+		return tx.Abort(err)
+	}
+	return tx.Commit()
+}
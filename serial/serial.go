@@ -0,0 +1,59 @@
+package serial
+
+import (
+	"fmt"
+	"time"
+)
+
+type Scheme string
+
+const (
+	Date      Scheme = "date"
+	Unix      Scheme = "unix"
+	Increment Scheme = "increment"
+)
+
+const DefaultScheme = Date
+
+func ParseScheme(s string) (Scheme, error) {
+	switch Scheme(s) {
+	case Date, Unix, Increment:
+		return Scheme(s), nil
+	case "":
+		return DefaultScheme, nil
+	}
+	return "", fmt.Errorf("unknown serial scheme: %s", s)
+}
+
+// Less reports whether a precedes b in RFC 1982 serial number arithmetic.
+func Less(a, b uint32) bool {
+	return a != b && int32(b-a) > 0
+}
+
+// Next returns the serial that should replace current. The result is always
+// greater than current in serial number arithmetic, falling back to a plain
+// increment when the scheme's candidate would not be.
+func Next(scheme Scheme, current uint32, now time.Time) uint32 {
+	var candidate uint32
+	switch scheme {
+	case Date:
+		now = now.UTC()
+		candidate = uint32(now.Year()*1000000 + int(now.Month())*10000 + now.Day()*100)
+	case Unix:
+		candidate = uint32(now.Unix())
+	default:
+		return current + 1
+	}
+	if Less(current, candidate) {
+		return candidate
+	}
+	return current + 1
+}
+
+func Initial(scheme Scheme, now time.Time) uint32 {
+	switch scheme {
+	case Increment:
+		return 1
+	}
+	return Next(scheme, 0, now)
+}
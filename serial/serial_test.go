@@ -0,0 +1,88 @@
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLess(t *testing.T) {
+	tests := []struct {
+		a, b uint32
+		want bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{5, 5, false},
+		{4294967295, 0, true},
+		{0, 4294967295, false},
+		{0, 2147483647, true},
+		{0, 2147483648, false},
+	}
+	for _, tt := range tests {
+		if got := Less(tt.a, tt.b); got != tt.want {
+			t.Errorf("Less(%d, %d) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	now := time.Date(2024, 3, 9, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		scheme  Scheme
+		current uint32
+		want    uint32
+	}{
+		{"date from older serial", Date, 2024030100, 2024030900},
+		{"date same day", Date, 2024030900, 2024030901},
+		{"date ahead of today", Date, 2024031005, 2024031006},
+		{"date from increment", Date, 7, 2024030900},
+		{"unix", Unix, 100, uint32(now.Unix())},
+		{"unix ahead of clock", Unix, uint32(now.Unix()) + 10, uint32(now.Unix()) + 11},
+		{"increment", Increment, 41, 42},
+		{"increment past 2^31", Increment, 3000000000, 3000000001},
+		{"increment wraps", Increment, 4294967295, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Next(tt.scheme, tt.current, now)
+			if got != tt.want {
+				t.Errorf("Next(%s, %d) = %d, want %d", tt.scheme, tt.current, got, tt.want)
+			}
+			if !Less(tt.current, got) {
+				t.Errorf("Next(%s, %d) = %d does not follow the current serial", tt.scheme, tt.current, got)
+			}
+		})
+	}
+}
+
+func TestInitial(t *testing.T) {
+	now := time.Date(2024, 3, 9, 23, 0, 0, 0, time.FixedZone("east", -3*3600))
+	tests := []struct {
+		scheme Scheme
+		want   uint32
+	}{
+		{Date, 2024031000},
+		{Unix, uint32(now.Unix())},
+		{Increment, 1},
+	}
+	for _, tt := range tests {
+		if got := Initial(tt.scheme, now); got != tt.want {
+			t.Errorf("Initial(%s) = %d, want %d", tt.scheme, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheme(t *testing.T) {
+	for _, s := range []string{"date", "unix", "increment"} {
+		if got, err := ParseScheme(s); err != nil || string(got) != s {
+			t.Errorf("ParseScheme(%q) = %q, %v", s, got, err)
+		}
+	}
+	if got, err := ParseScheme(""); err != nil || got != DefaultScheme {
+		t.Errorf("ParseScheme(\"\") = %q, %v, want %q", got, err, DefaultScheme)
+	}
+	if _, err := ParseScheme("weekly"); err == nil {
+		t.Error("ParseScheme(\"weekly\") succeeded")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
+
+	"github.com/AfazTech/b9m/config"
 )
 
 func detectBindServiceName() string {
@@ -18,10 +21,39 @@ func detectBindServiceName() string {
 	return "named"
 }
 
-var bindServiceName = detectBindServiceName()
+var (
+	detectOnce      sync.Once
+	detectedService string
+)
+
+func serviceName(inst *config.Instance) string {
+	if inst.ServiceName != "" {
+		return inst.ServiceName
+	}
+	detectOnce.Do(func() { detectedService = detectBindServiceName() })
+	return detectedService
+}
+
+func rndc(inst *config.Instance, args ...string) *exec.Cmd {
+	bin := inst.Rndc
+	if bin == "" {
+		bin = config.DefaultRndc
+	}
+	var opts []string
+	if inst.RndcServer != "" {
+		opts = append(opts, "-s", inst.RndcServer)
+	}
+	if inst.RndcPort != "" {
+		opts = append(opts, "-p", inst.RndcPort)
+	}
+	if inst.RndcKey != "" {
+		opts = append(opts, "-k", inst.RndcKey)
+	}
+	return exec.Command(bin, append(opts, args...)...)
+}
 
-func ReloadBind() error {
-	cmd := exec.Command("rndc", "reload")
+func ReloadBind(inst *config.Instance) error {
+	cmd := rndc(inst, "reload")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to reload Bind: %w | output: %s", err, string(output))
@@ -29,8 +61,8 @@ func ReloadBind() error {
 	return nil
 }
 
-func RestartBind() error {
-	cmd := exec.Command("systemctl", "restart", bindServiceName)
+func RestartBind(inst *config.Instance) error {
+	cmd := exec.Command("systemctl", "restart", serviceName(inst))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to restart Bind: %w | output: %s", err, string(output))
@@ -38,8 +70,8 @@ func RestartBind() error {
 	return nil
 }
 
-func StopBind() error {
-	cmd := exec.Command("systemctl", "stop", bindServiceName)
+func StopBind(inst *config.Instance) error {
+	cmd := exec.Command("systemctl", "stop", serviceName(inst))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to stop Bind: %w | output: %s", err, string(output))
@@ -47,8 +79,8 @@ func StopBind() error {
 	return nil
 }
 
-func StartBind() error {
-	cmd := exec.Command("systemctl", "start", bindServiceName)
+func StartBind(inst *config.Instance) error {
+	cmd := exec.Command("systemctl", "start", serviceName(inst))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to start Bind: %w | output: %s", err, string(output))
@@ -56,11 +88,31 @@ func StartBind() error {
 	return nil
 }
 
-func StatusBind() (string, error) {
-	cmd := exec.Command("systemctl", "is-active", bindServiceName)
+func StatusBind(inst *config.Instance) (string, error) {
+	cmd := exec.Command("systemctl", "is-active", serviceName(inst))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to get Bind status: %w | output: %s", err, string(output))
 	}
 	return strings.TrimSpace(string(output)), nil
 }
+
+// ZoneStatus returns the fields reported by rndc zonestatus for domain in
+// view, keyed by their label, for example "serial" or "next refresh".
+func ZoneStatus(inst *config.Instance, view, domain string) (map[string]string, error) {
+	args := []string{"zonestatus", domain}
+	if view != "" {
+		args = append(args, "IN", view)
+	}
+	output, err := rndc(inst, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of zone %s: %w | output: %s", domain, err, string(output))
+	}
+	status := map[string]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			status[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/AfazTech/b9m/parser"
+)
+
+// WriteFileAtomic replaces path with data through a synced temporary file in
+// the same directory, keeping the mode and ownership of the file it replaces.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	uid, gid := -1, -1
+	if info, err := os.Stat(path); err == nil {
+		perm, uid, gid = fileOwnership(info)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return writeFileAtomic(path, data, perm, uid, gid)
+}
+
+func fileOwnership(info os.FileInfo) (os.FileMode, int, int) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return info.Mode().Perm(), int(st.Uid), int(st.Gid)
+	}
+	return info.Mode().Perm(), -1, -1
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode, uid, gid int) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".b9m-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if uid >= 0 && (uid != os.Geteuid() || gid != os.Getegid()) {
+		if err := tmp.Chown(uid, gid); err != nil && os.Geteuid() == 0 {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+type fileBackup struct {
+	path    string
+	existed bool
+	data    []byte
+	perm    os.FileMode
+	uid     int
+	gid     int
+}
+
+// Transaction applies file changes immediately and remembers the original
+// contents so that every change can be undone if a later step fails.
+type Transaction struct {
+	backups []fileBackup
+	touched map[string]bool
+}
+
+func NewTransaction() *Transaction {
+	return &Transaction{touched: map[string]bool{}}
+}
+
+func (t *Transaction) backup(path string) error {
+	if t.touched[path] {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err == nil {
+		var data []byte
+		if data, err = os.ReadFile(path); err == nil {
+			perm, uid, gid := fileOwnership(info)
+			t.backups = append(t.backups, fileBackup{path: path, existed: true, data: data, perm: perm, uid: uid, gid: gid})
+		}
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist):
+		t.backups = append(t.backups, fileBackup{path: path})
+	default:
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	t.touched[path] = true
+	return nil
+}
+
+func (t *Transaction) WriteFile(path string, data []byte) error {
+	if err := t.backup(path); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, 0644)
+}
+
+func (t *Transaction) Remove(path string) error {
+	if err := t.backup(path); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func (t *Transaction) WriteZone(zf *parser.ZoneFile) error {
+	return t.WriteFile(zf.Path, zf.Bytes())
+}
+
+func (t *Transaction) WriteConfig(conf *parser.Config) error {
+	for _, path := range conf.Modified() {
+		if err := t.WriteFile(path, conf.Source(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Transaction) Rollback() error {
+	var errs []error
+	for i := len(t.backups) - 1; i >= 0; i-- {
+		b := t.backups[i]
+		var err error
+		if b.existed {
+			err = writeFileAtomic(b.path, b.data, b.perm, b.uid, b.gid)
+		} else if err = os.Remove(b.path); errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", b.path, err))
+		}
+	}
+	t.backups = nil
+	t.touched = map[string]bool{}
+	return errors.Join(errs...)
+}
+
+// Abort rolls the transaction back and returns err, annotated with any
+// failure to restore the original files.
+func (t *Transaction) Abort(err error) error {
+	if rbErr := t.Rollback(); rbErr != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+	}
+	return err
+}
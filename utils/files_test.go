@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteFileAtomicKeepsModeAndOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zone")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	owner := os.Geteuid() == 0
+	if owner {
+		if err := os.Chown(path, 1234, 5678); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("file holds %q, want %q", data, "new")
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	if st := info.Sys().(*syscall.Stat_t); owner && (st.Uid != 1234 || st.Gid != 5678) {
+		t.Errorf("owner = %d:%d, want 1234:5678", st.Uid, st.Gid)
+	}
+
+	fresh := filepath.Join(filepath.Dir(path), "fresh")
+	if err := WriteFileAtomic(fresh, []byte("x"), 0604); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(fresh); err != nil || info.Mode().Perm() != 0604 {
+		t.Errorf("new file mode = %v, %v, want %v", info.Mode().Perm(), err, os.FileMode(0604))
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*")); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %q", tmp)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	removed := filepath.Join(dir, "removed")
+	created := filepath.Join(dir, "created")
+	if err := os.WriteFile(existing, []byte("one"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(removed, []byte("two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewTransaction()
+	for _, step := range []error{
+		tx.WriteFile(existing, []byte("changed")),
+		tx.WriteFile(existing, []byte("changed twice")),
+		tx.Remove(removed),
+		tx.WriteFile(created, []byte("new")),
+	} {
+		if step != nil {
+			t.Fatal(step)
+		}
+	}
+	failure := errors.New("reload failed")
+	if err := tx.Abort(failure); err != failure {
+		t.Fatalf("Abort() = %v, want %v", err, failure)
+	}
+
+	for path, want := range map[string]string{existing: "one", removed: "two"} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("%s holds %q, %v after rollback, want %q", filepath.Base(path), data, err, want)
+		}
+	}
+	if info, err := os.Stat(existing); err == nil && info.Mode().Perm() != 0640 {
+		t.Errorf("mode after rollback = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	if _, err := os.Stat(created); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("created file still exists after rollback: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("second Rollback() = %v", err)
+	}
+}
@@ -17,7 +17,7 @@ func ValidateSubdomain(sub string) error {
 	if sub == "@" {
 		return nil
 	}
-	matched, _ := regexp.MatchString("^[a-zA-Z0-9-]{1,63}$", sub)
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]{1,63}(\.[a-zA-Z0-9_-]{1,63})*$`, sub)
 	if !matched {
 		return fmt.Errorf("invalid subdomain format: %s", sub)
 	}
@@ -31,6 +31,17 @@ func ValidateDomain(domain string) error {
 	return nil
 }
 
+// ValidateView accepts an empty view, meaning zones outside of views.
+func ValidateView(view string) error {
+	if view == "" {
+		return nil
+	}
+	if matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`, view); !matched {
+		return fmt.Errorf("invalid view name: %s", view)
+	}
+	return nil
+}
+
 func ValidateARecord(nsName string) error {
 	c := &dns.Client{Timeout: 2 * time.Second}
 	m := new(dns.Msg)
@@ -56,52 +67,65 @@ func ValidateIP(ip string) error {
 	}
 	return nil
 }
-func DomainExists(domain string) (bool, error) {
-	domains, err := parser.GetDomains()
+
+// ValidateRData checks rdata in zone file presentation format using the
+// same rules named applies when loading the zone.
+func ValidateRData(rType, rdata string) error {
+	rr, err := dns.NewRR(fmt.Sprintf("check.invalid. 3600 IN %s %s", rType, rdata))
 	if err != nil {
-		return false, fmt.Errorf("failed to retrieve domains for existence check on %s: %w", domain, err)
+		return fmt.Errorf("invalid %s record data %q: %w", rType, rdata, err)
 	}
-	_, exists := domains[domain]
-	return exists, nil
+	if rr == nil {
+		return fmt.Errorf("invalid %s record data: empty", rType)
+	}
+	return nil
 }
 
-func Backup(backupDir string) error {
-	confFile := config.GetConfigFile()
-
-	backupConfigPath := filepath.Join(backupDir, confFile)
-	if err := os.MkdirAll(filepath.Dir(backupConfigPath), 0755); err != nil {
-		return err
+func DomainExists(inst *config.Instance, view, domain string) (bool, error) {
+	domains, err := parser.GetDomains(inst)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve domains for existence check on %s: %w", domain, err)
 	}
+	_, exists := parser.FindDomain(domains, view, domain)
+	return exists, nil
+}
 
-	input, err := os.ReadFile(confFile)
+func Backup(inst *config.Instance, backupDir string) error {
+	config, err := parser.LoadConfig(inst)
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(backupConfigPath, input, 0644); err != nil {
-		return err
-	}
 
-	config, err := parser.ParseConfig(confFile)
-	if err != nil {
-		return err
+	for _, f := range config.Files {
+		backupConfigPath := filepath.Join(backupDir, f.Path)
+		if err := os.MkdirAll(filepath.Dir(backupConfigPath), 0755); err != nil {
+			return err
+		}
+		if err := WriteFileAtomic(backupConfigPath, f.Src, 0644); err != nil {
+			return err
+		}
 	}
 
-	for _, v := range config {
-		if zone, ok := v.(map[string]interface{}); ok {
-			if t, exists := zone["type"]; exists && t == "master" {
-				if src, ok := zone["file"].(string); ok {
-					backupFilePath := filepath.Join(backupDir, src)
-					if err := os.MkdirAll(filepath.Dir(backupFilePath), 0755); err != nil {
-						continue
-					}
-					input, err := os.ReadFile(src)
-					if err != nil {
-						continue
-					}
-					os.WriteFile(backupFilePath, input, 0644)
-				}
+	for _, zone := range config.Zones() {
+		if (!zone.IsPrimary() && !zone.IsSecondary() && !zone.IsStub()) || zone.File == "" {
+			continue
+		}
+		srcs := []string{config.ZonePath(zone)}
+		if zone.IsPrimary() {
+			if zf, err := config.LoadZone(zone); err == nil {
+				srcs = zf.Files()
 			}
-
+		}
+		for _, src := range srcs {
+			backupFilePath := filepath.Join(backupDir, src)
+			if err := os.MkdirAll(filepath.Dir(backupFilePath), 0755); err != nil {
+				continue
+			}
+			input, err := os.ReadFile(src)
+			if err != nil {
+				continue
+			}
+			WriteFileAtomic(backupFilePath, input, 0644)
 		}
 	}
 	return nil
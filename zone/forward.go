@@ -0,0 +1,63 @@
+package zone
+
+import (
+	"fmt"
+
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/parser"
+)
+
+// ForwardOptions describe a forward zone. Forward is "only" or "first", or
+// empty for named's default of first.
+type ForwardOptions struct {
+	Forwarders []string `json:"forwarders"`
+	Forward    string   `json:"forward,omitempty"`
+}
+
+func AddForward(inst *config.Instance, view, domain string, opts ForwardOptions) error {
+	return editConfigZone(inst, view, domain, "add forward domain", func(conf *parser.Config) error {
+		if err := validateForward(opts); err != nil {
+			return err
+		}
+		if err := appendZone(conf, view, domain, fmt.Sprintf("zone %s {\n\ttype forward;\n};", parser.Quote(domain))); err != nil {
+			return err
+		}
+		return setForward(conf, view, domain, opts)
+	})
+}
+
+// UpdateForward replaces the forwarders and forward policy of an existing
+// forward zone.
+func UpdateForward(inst *config.Instance, view, domain string, opts ForwardOptions) error {
+	return editConfigZone(inst, view, domain, "update forward domain", func(conf *parser.Config) error {
+		if _, err := findZoneOfType(conf, view, domain, "forward", (*parser.Zone).IsForward); err != nil {
+			return err
+		}
+		if err := validateForward(opts); err != nil {
+			return err
+		}
+		return setForward(conf, view, domain, opts)
+	})
+}
+
+func validateForward(opts ForwardOptions) error {
+	if len(opts.Forwarders) == 0 {
+		return fmt.Errorf("at least one forwarder is required")
+	}
+	for _, f := range opts.Forwarders {
+		if err := validateAddress(f); err != nil {
+			return fmt.Errorf("invalid forwarder: %w", err)
+		}
+	}
+	if opts.Forward != "" && opts.Forward != "only" && opts.Forward != "first" {
+		return fmt.Errorf("forward must be only or first, not %q", opts.Forward)
+	}
+	return nil
+}
+
+func setForward(conf *parser.Config, view, domain string, opts ForwardOptions) error {
+	return setZoneOptions(conf, view, domain, []zoneOption{
+		{"forward", opts.Forward},
+		{"forwarders", addressList(opts.Forwarders)},
+	})
+}
@@ -0,0 +1,47 @@
+package zone
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForward(t *testing.T) {
+	inst := newTestInstance(t, viewsConf, map[string]string{})
+	opts := ForwardOptions{Forwarders: []string{"192.0.2.53", "192.0.2.54 port 5353"}, Forward: "only"}
+	if err := AddForward(inst, "int", "corp.example", opts); err != nil {
+		t.Fatal(err)
+	}
+	z := readConf(t, inst).FindZone("corp.example", "int")
+	if z == nil || !z.IsForward() {
+		t.Fatalf("forward zone corp.example not added to view int: %+v", z)
+	}
+	if !reflect.DeepEqual(z.Forwarders, opts.Forwarders) || z.Forward != "only" {
+		t.Errorf("forwarders = %q, forward %q, want %q, only", z.Forwarders, z.Forward, opts.Forwarders)
+	}
+
+	opts = ForwardOptions{Forwarders: []string{"2001:db8::53"}}
+	if err := UpdateForward(inst, "int", "corp.example", opts); err != nil {
+		t.Fatal(err)
+	}
+	z = readConf(t, inst).FindZone("corp.example", "int")
+	if !reflect.DeepEqual(z.Forwarders, opts.Forwarders) || z.Forward != "" {
+		t.Errorf("after update forwarders = %q, forward %q, want %q and no policy", z.Forwarders, z.Forward, opts.Forwarders)
+	}
+
+	for name, edit := range map[string]func() error{
+		"existing zone": func() error { return AddForward(inst, "int", "corp.example", opts) },
+		"no forwarders": func() error { return AddForward(inst, "int", "lab.example", ForwardOptions{}) },
+		"bad forwarder": func() error {
+			return AddForward(inst, "int", "lab.example", ForwardOptions{Forwarders: []string{"dns.example"}})
+		},
+		"bad policy": func() error {
+			return UpdateForward(inst, "int", "corp.example", ForwardOptions{Forwarders: []string{"192.0.2.53"}, Forward: "last"})
+		},
+		"missing zone":  func() error { return UpdateForward(inst, "ext", "corp.example", opts) },
+		"not a forward": func() error { return UpdateForward(inst, "int", "example.com", opts) },
+	} {
+		if err := edit(); err == nil {
+			t.Errorf("%s: edit succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package zone
+
+import (
+	"fmt"
+
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/lock"
+	"github.com/AfazTech/b9m/parser"
+	"github.com/AfazTech/b9m/record"
+	"github.com/AfazTech/b9m/utils"
+)
+
+// ReverseDomains lists the reverse domains added for a CIDR. Delegation
+// holds the records an RFC 2317 classless domain needs in its parent zone
+// when that zone is not managed here; otherwise they are added to it.
+type ReverseDomains struct {
+	Domains    []string `json:"domains"`
+	Delegation []string `json:"delegation,omitempty"`
+}
+
+// AddReverseDomain adds the in-addr.arpa or ip6.arpa domains covering cidr,
+// as named by parser.ReverseZones. Either every domain is added, delegated
+// from its managed parent if it is classless, or none is.
+func AddReverseDomain(inst *config.Instance, view, cidr string, opts DomainOptions) (ReverseDomains, error) {
+	var result ReverseDomains
+	zones, err := parser.ReverseZones(cidr)
+	if err != nil {
+		return result, fmt.Errorf("failed to add reverse domain: %w", err)
+	}
+	if err := utils.ValidateView(view); err != nil {
+		return result, fmt.Errorf("failed to add reverse domain: %w", err)
+	}
+	confLock, err := lock.Config(inst.Name)
+	if err != nil {
+		return result, err
+	}
+	defer confLock.Unlock()
+	tx := record.NewZoneTx(inst, view)
+	defer tx.Unlock()
+	for _, z := range zones {
+		if err := tx.Lock(z); err != nil {
+			return ReverseDomains{}, tx.Abort(err)
+		}
+		if err := addDomain(inst, tx.Transaction, view, z, opts); err != nil {
+			return ReverseDomains{}, tx.Abort(err)
+		}
+		tx.Changed()
+		result.Domains = append(result.Domains, z)
+		if _, parent, ok := parser.ClasslessZone(z); ok {
+			if err := delegateClassless(inst, tx, view, z, parent, opts.Nameservers, &result); err != nil {
+				return ReverseDomains{}, tx.Abort(fmt.Errorf("failed to delegate reverse domain %s from %s: %w", z, parent, err))
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return ReverseDomains{}, err
+	}
+	return result, nil
+}
+
+func delegateClassless(inst *config.Instance, tx *record.ZoneTx, view, zone, parent string, nameservers []string, result *ReverseDomains) error {
+	domains, err := parser.GetDomains(inst)
+	if err != nil {
+		return err
+	}
+	if d, ok := parser.FindDomain(domains, view, parent); ok && d.IsPrimary() {
+		return record.AddClasslessDelegation(tx, zone, nameservers)
+	}
+	records, err := parser.ClasslessDelegation(zone, nameservers)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		result.Delegation = append(result.Delegation, fmt.Sprintf("%s %d IN %s %s", rec.Name, rec.TTL, rec.Type, rec.RData))
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package zone
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AfazTech/b9m/checker"
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/lock"
+	"github.com/AfazTech/b9m/parser"
+	"github.com/AfazTech/b9m/servicemanager"
+	"github.com/AfazTech/b9m/utils"
+)
+
+// SecondaryOptions describe where a secondary or stub zone is transferred
+// from. Primaries are addresses, optionally followed by "port N", or names
+// of primaries lists; Key is the TSIG key used for every primary.
+type SecondaryOptions struct {
+	Primaries      []string `json:"primaries"`
+	Key            string   `json:"key,omitempty"`
+	TransferSource string   `json:"transfer_source,omitempty"`
+}
+
+func AddSecondary(inst *config.Instance, view, domain string, opts SecondaryOptions) error {
+	return addTransferredZone(inst, view, domain, "secondary", "slave", opts)
+}
+
+// UpdateSecondary replaces the primaries, key and transfer source of an
+// existing secondary zone.
+func UpdateSecondary(inst *config.Instance, view, domain string, opts SecondaryOptions) error {
+	return updateTransferredZone(inst, view, domain, "secondary", (*parser.Zone).IsSecondary, opts)
+}
+
+func AddStub(inst *config.Instance, view, domain string, opts SecondaryOptions) error {
+	return addTransferredZone(inst, view, domain, "stub", "stub", opts)
+}
+
+// UpdateStub replaces the primaries, key and transfer source of an existing
+// stub zone.
+func UpdateStub(inst *config.Instance, view, domain string, opts SecondaryOptions) error {
+	return updateTransferredZone(inst, view, domain, "stub", (*parser.Zone).IsStub, opts)
+}
+
+func addTransferredZone(inst *config.Instance, view, domain, kind, zoneType string, opts SecondaryOptions) error {
+	return editConfigZone(inst, view, domain, "add "+kind+" domain", func(conf *parser.Config) error {
+		if err := validateSecondary(conf, opts); err != nil {
+			return err
+		}
+		hostFile := filepath.Join(inst.ZoneDir, view, domain+"."+zoneType)
+		if err := os.MkdirAll(filepath.Dir(hostFile), 0755); err != nil {
+			return fmt.Errorf("failed to create zone directory for domain %s: %w", domain, err)
+		}
+		zoneFile, err := conf.NamedPath(hostFile)
+		if err != nil {
+			return err
+		}
+		if err := appendZone(conf, view, domain, fmt.Sprintf("zone %s {\n\ttype %s;\n\tfile %s;\n};", parser.Quote(domain), zoneType, parser.Quote(zoneFile))); err != nil {
+			return err
+		}
+		return setSecondary(conf, view, domain, opts)
+	})
+}
+
+func updateTransferredZone(inst *config.Instance, view, domain, kind string, is func(*parser.Zone) bool, opts SecondaryOptions) error {
+	return editConfigZone(inst, view, domain, "update "+kind+" domain", func(conf *parser.Config) error {
+		if _, err := findZoneOfType(conf, view, domain, kind, is); err != nil {
+			return err
+		}
+		if err := validateSecondary(conf, opts); err != nil {
+			return err
+		}
+		return setSecondary(conf, view, domain, opts)
+	})
+}
+
+// GetDomains lists the domains of inst together with the transfer status
+// of the secondary and stub zones.
+func GetDomains(inst *config.Instance) ([]parser.Domain, error) {
+	domains, err := parser.GetDomains(inst)
+	if err != nil {
+		return nil, err
+	}
+	for i, d := range domains {
+		if d.IsTransferred() {
+			domains[i].Transfer = transferStatus(inst, d)
+		}
+	}
+	return domains, nil
+}
+
+func transferStatus(inst *config.Instance, d parser.Domain) *parser.TransferStatus {
+	status, err := servicemanager.ZoneStatus(inst, d.View, d.Name)
+	if err != nil {
+		return &parser.TransferStatus{State: "unknown", Error: err.Error()}
+	}
+	ts := &parser.TransferStatus{
+		State:       "pending",
+		Serial:      status["serial"],
+		LastLoaded:  status["last loaded"],
+		NextRefresh: status["next refresh"],
+		Expires:     status["expires"],
+	}
+	if ts.Serial != "" {
+		ts.State = "transferred"
+	}
+	return ts
+}
+
+// validateAddress accepts an IP address optionally followed by "port N".
+func validateAddress(s string) error {
+	fields := strings.Fields(s)
+	if (len(fields) != 1 && (len(fields) != 3 || fields[1] != "port")) || net.ParseIP(fields[0]) == nil {
+		return fmt.Errorf("invalid address %q", s)
+	}
+	if len(fields) == 3 {
+		if port, err := strconv.Atoi(fields[2]); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port in address %q", s)
+		}
+	}
+	return nil
+}
+
+func validateSecondary(conf *parser.Config, opts SecondaryOptions) error {
+	if len(opts.Primaries) == 0 {
+		return fmt.Errorf("at least one primary is required")
+	}
+	lists := map[string]bool{}
+	for _, l := range conf.PrimariesLists() {
+		lists[l.Name] = true
+	}
+	for _, p := range opts.Primaries {
+		if lists[p] {
+			continue
+		}
+		if err := validateAddress(p); err != nil {
+			return fmt.Errorf("invalid primary %q: not an address or primaries list", p)
+		}
+	}
+	if opts.Key != "" && strings.ContainsAny(opts.Key, "\"; {}") {
+		return fmt.Errorf("invalid key name %q", opts.Key)
+	}
+	if opts.TransferSource != "" && net.ParseIP(opts.TransferSource) == nil {
+		return fmt.Errorf("invalid transfer source %q", opts.TransferSource)
+	}
+	return nil
+}
+
+// setSecondary writes opts into the zone statement of domain, keeping the
+// primaries keyword the zone already uses.
+func setSecondary(conf *parser.Config, view, domain string, opts SecondaryOptions) error {
+	keyword := "masters"
+	if conf.FindZone(domain, view).Stmt.Find("primaries") != nil {
+		keyword = "primaries"
+	}
+	var primaries []string
+	for _, p := range opts.Primaries {
+		if opts.Key != "" {
+			p += " key " + parser.Quote(opts.Key)
+		}
+		primaries = append(primaries, p)
+	}
+	source, otherSource := "transfer-source", "transfer-source-v6"
+	if ip := net.ParseIP(opts.TransferSource); ip != nil && ip.To4() == nil {
+		source, otherSource = otherSource, source
+	}
+	return setZoneOptions(conf, view, domain, []zoneOption{
+		{keyword, addressList(primaries)},
+		{source, opts.TransferSource},
+		{otherSource, ""},
+	})
+}
+
+func addressList(addresses []string) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for _, a := range addresses {
+		b.WriteString(" " + a + ";")
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+type zoneOption struct {
+	name, value string
+}
+
+// setZoneOptions sets each option in the zone statement of domain, removing
+// those with an empty value.
+func setZoneOptions(conf *parser.Config, view, domain string, options []zoneOption) error {
+	for _, o := range options {
+		z := conf.FindZone(domain, view)
+		if o.value != "" {
+			if err := conf.SetOption(z.Stmt, o.name, o.value); err != nil {
+				return err
+			}
+		} else if z.Stmt.Find(o.name) != nil {
+			if err := conf.UnsetOption(z.Stmt, o.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// editConfigZone runs edit on the configuration under the config and zone
+// locks, then validates and writes it, restoring the previous files if
+// named refuses to reload them.
+func editConfigZone(inst *config.Instance, view, domain, action string, edit func(conf *parser.Config) error) error {
+	if err := utils.ValidateDomain(domain); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, domain, err)
+	}
+	if err := utils.ValidateView(view); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, domain, err)
+	}
+	confLock, err := lock.Config(inst.Name)
+	if err != nil {
+		return err
+	}
+	defer confLock.Unlock()
+	zoneLock, err := lock.Zone(inst.Name, view, domain)
+	if err != nil {
+		return err
+	}
+	defer zoneLock.Unlock()
+	conf, err := parser.LoadConfig(inst)
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %w", inst.ConfigFile, err)
+	}
+	if err := edit(conf); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, domain, err)
+	}
+	if err := checker.CheckConfig(conf); err != nil {
+		return fmt.Errorf("refusing to %s %s: %w", action, domain, err)
+	}
+	tx := utils.NewTransaction()
+	if err := tx.WriteConfig(conf); err != nil {
+		return tx.Abort(fmt.Errorf("failed to %s %s: %w", action, domain, err))
+	}
+	if err := servicemanager.ReloadBind(inst); err != nil {
+		return tx.Abort(err)
+	}
+	return nil
+}
+
+// appendZone adds the zone statement text for domain to view, or to the top
+// level if view is empty.
+func appendZone(conf *parser.Config, view, domain, text string) error {
+	if conf.FindZone(domain, view) != nil {
+		return fmt.Errorf("domain already exists: %s", parser.DomainName(view, domain))
+	}
+	parent, err := viewStatement(conf, view)
+	if err != nil {
+		return err
+	}
+	return conf.AppendStatement(conf.Root, parent, text)
+}
+
+func findZoneOfType(conf *parser.Config, view, domain, kind string, is func(*parser.Zone) bool) (*parser.Zone, error) {
+	z := conf.FindZone(domain, view)
+	if z == nil {
+		return nil, fmt.Errorf("zone for domain %s not found in configuration", parser.DomainName(view, domain))
+	}
+	if !is(z) {
+		return nil, fmt.Errorf("%s is a %s zone, not a %s zone", parser.DomainName(view, domain), z.Type, kind)
+	}
+	return z, nil
+}
+
+func viewStatement(conf *parser.Config, view string) (*parser.Statement, error) {
+	if view == "" {
+		return nil, nil
+	}
+	v := findView(conf, view)
+	if v == nil {
+		return nil, fmt.Errorf("view %s not found in configuration", view)
+	}
+	return v.Stmt, nil
+}
@@ -0,0 +1,68 @@
+package zone
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSecondary(t *testing.T) {
+	inst := newTestInstance(t, viewsConf, map[string]string{
+		"int/example.com.db": testZone,
+		"ext/example.com.db": testZone,
+	})
+	opts := SecondaryOptions{Primaries: []string{"192.0.2.1", "192.0.2.2 port 5353"}, Key: "xfr"}
+	if err := AddSecondary(inst, "int", "example.net", opts); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(inst.ZoneDir, "int")); err != nil || !info.IsDir() {
+		t.Errorf("zone directory of view int missing: %v", err)
+	}
+	z := readConf(t, inst).FindZone("example.net", "int")
+	if z == nil || !z.IsSecondary() {
+		t.Fatalf("secondary zone example.net not added to view int: %+v", z)
+	}
+	if want := []string{"192.0.2.1 key xfr", "192.0.2.2 port 5353 key xfr"}; !reflect.DeepEqual(z.Primaries, want) {
+		t.Errorf("primaries = %q, want %q", z.Primaries, want)
+	}
+	if want := filepath.Join(inst.ZoneDir, "int", "example.net.slave"); z.File != want {
+		t.Errorf("file = %s, want %s", z.File, want)
+	}
+
+	opts = SecondaryOptions{Primaries: []string{"2001:db8::1"}, TransferSource: "2001:db8::53"}
+	if err := UpdateSecondary(inst, "int", "example.net", opts); err != nil {
+		t.Fatal(err)
+	}
+	z = readConf(t, inst).FindZone("example.net", "int")
+	if want := []string{"2001:db8::1"}; !reflect.DeepEqual(z.Primaries, want) {
+		t.Errorf("primaries after update = %q, want %q", z.Primaries, want)
+	}
+	if s := z.Stmt.Find("transfer-source-v6"); s == nil || s.Arg(0) != "2001:db8::53" {
+		t.Error("transfer-source-v6 not set")
+	}
+
+	if err := AddStub(inst, "ext", "example.org", SecondaryOptions{Primaries: []string{"192.0.2.3"}}); err != nil {
+		t.Fatal(err)
+	}
+	if z := readConf(t, inst).FindZone("example.org", "ext"); z == nil || !z.IsStub() {
+		t.Errorf("stub zone example.org not added to view ext: %+v", z)
+	}
+
+	for name, edit := range map[string]func() error{
+		"existing zone": func() error { return AddSecondary(inst, "int", "example.net", opts) },
+		"no primaries":  func() error { return AddSecondary(inst, "int", "example.info", SecondaryOptions{}) },
+		"bad primary": func() error {
+			return AddSecondary(inst, "int", "example.info", SecondaryOptions{Primaries: []string{"ns1"}})
+		},
+		"unknown key": func() error {
+			return AddSecondary(inst, "int", "example.info", SecondaryOptions{Primaries: []string{"192.0.2.1"}, Key: "nokey"})
+		},
+		"primary zone":  func() error { return UpdateSecondary(inst, "int", "example.com", opts) },
+		"stub as slave": func() error { return UpdateSecondary(inst, "ext", "example.org", opts) },
+	} {
+		if err := edit(); err == nil {
+			t.Errorf("%s: edit succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package zone
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/parser"
+	"github.com/AfazTech/b9m/serial"
+)
+
+// DefaultTemplate is the name of the template used when none is given. It
+// can be overridden like any other template.
+const DefaultTemplate = "default"
+
+const defaultTemplateText = `$TTL 86400
+@ IN SOA {{.NS1}}. admin.{{.Domain}}. {{.Serial}} 86400 3600 604800 86400
+{{range .Nameservers}}@ IN NS {{.}}.
+{{end}}`
+
+// templateExt is the extension of template files in the template directory;
+// the rest of the file name is the template name.
+const templateExt = ".zone"
+
+var templateNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`)
+
+// Template is a zone file in text/template syntax, executed with
+// TemplateData to produce the zone file of a new domain. Source is
+// "builtin", "settings" or the path of the template file.
+type Template struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Text   string `json:"-"`
+}
+
+// TemplateData holds the values templates refer to, as in {{.Domain}} or
+// {{range .Nameservers}}. Names are given without the trailing dot; NS1 and
+// NS2 are the first two nameservers, NS2 being empty if there is only one.
+type TemplateData struct {
+	Domain      string
+	NS1         string
+	NS2         string
+	Nameservers []string
+	Serial      uint32
+}
+
+func newTemplateData(domain string, nameservers []string, initialSerial uint32) TemplateData {
+	data := TemplateData{Domain: domain, Nameservers: nameservers, Serial: initialSerial}
+	data.NS1 = nameservers[0]
+	if len(nameservers) > 1 {
+		data.NS2 = nameservers[1]
+	}
+	return data
+}
+
+// Templates lists the templates of inst by name. Templates in the settings
+// take precedence over files in the template directory, which take
+// precedence over the built-in default.
+func Templates(inst *config.Instance) ([]Template, error) {
+	byName := map[string]Template{
+		DefaultTemplate: {Name: DefaultTemplate, Source: "builtin", Text: defaultTemplateText},
+	}
+	entries, err := os.ReadDir(inst.TemplateDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read template directory %s: %w", inst.TemplateDir, err)
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), templateExt)
+		if !ok || e.IsDir() || !templateNameRegex.MatchString(name) {
+			continue
+		}
+		path := filepath.Join(inst.TemplateDir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+		}
+		byName[name] = Template{Name: name, Source: path, Text: string(data)}
+	}
+	for name, text := range inst.Templates {
+		byName[name] = Template{Name: name, Source: "settings", Text: text}
+	}
+	var templates []Template
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// GetTemplate returns the template called name, or the default one if name
+// is empty.
+func GetTemplate(inst *config.Instance, name string) (Template, error) {
+	if name == "" {
+		name = DefaultTemplate
+	}
+	templates, err := Templates(inst)
+	if err != nil {
+		return Template{}, err
+	}
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Template{}, fmt.Errorf("template %s not found", name)
+}
+
+// Render executes t with data and parses the result as a zone file whose
+// diagnostics refer to the source of t.
+func (t Template) Render(data TemplateData) (*parser.ZoneFile, error) {
+	tmpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", t.Name, err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template %s: %w", t.Name, err)
+	}
+	zf := parser.ParseZone(b.Bytes(), data.Domain)
+	for i := range zf.Diagnostics {
+		zf.Diagnostics[i].File = t.Source
+	}
+	return zf, nil
+}
+
+// CheckTemplate renders the template called name for example.com, served by
+// nameservers outside of it, and lints the result like CheckZone. Line
+// numbers refer to the rendered zone.
+func CheckTemplate(inst *config.Instance, name string) ([]parser.Diagnostic, error) {
+	t, err := GetTemplate(inst, name)
+	if err != nil {
+		return nil, err
+	}
+	zf, err := t.Render(newTemplateData("example.com", []string{"ns1.example.net", "ns2.example.net"}, serial.Initial(serial.DefaultScheme, time.Now())))
+	if err != nil {
+		return []parser.Diagnostic{{File: t.Source, Severity: parser.SeverityError, Message: err.Error()}}, nil
+	}
+	zf.Path = t.Source
+	return lintZone("example.com", zf), nil
+}
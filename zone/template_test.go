@@ -0,0 +1,110 @@
+package zone
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/parser"
+)
+
+func TestTemplateRender(t *testing.T) {
+	data := newTemplateData("example.com", []string{"ns1.example.net", "ns2.example.net"}, 2024010100)
+	zf, err := Template{Name: DefaultTemplate, Source: "builtin", Text: defaultTemplateText}.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "$TTL 86400\n" +
+		"@ IN SOA ns1.example.net. admin.example.com. 2024010100 86400 3600 604800 86400\n" +
+		"@ IN NS ns1.example.net.\n" +
+		"@ IN NS ns2.example.net.\n"
+	if got := string(zf.Bytes()); got != want {
+		t.Errorf("rendered zone:\n%s\nwant:\n%s", got, want)
+	}
+	if err := parser.FirstError(zf.Diagnostics); err != nil {
+		t.Error(err)
+	}
+	if soa := zf.Data().Records[0]; soa.Name != "example.com." {
+		t.Errorf("SOA owner = %s, want the domain", soa.Name)
+	}
+
+	for name, text := range map[string]string{
+		"syntax":      "{{.Domain",
+		"missing key": "{{.Owner}}",
+	} {
+		if _, err := (Template{Name: name, Text: text}).Render(data); err == nil {
+			t.Errorf("Render() of a template with a %s error succeeded", name)
+		}
+	}
+
+	zf, err = Template{Name: "bad", Source: "/etc/b9m/templates/bad.zone", Text: "www IN A {{.NS2}}\n"}.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parser.FirstError(zf.Diagnostics); err == nil || !strings.HasPrefix(err.Error(), "/etc/b9m/templates/bad.zone:1") {
+		t.Errorf("diagnostic = %v, want one referring to the template file", err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"default.zone": "file default\n",
+		"web.zone":     "file web\n",
+		"mail.zone":    "file mail\n",
+		"notes.txt":    "ignored\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inst := &config.Instance{Profile: config.Profile{
+		TemplateDir: dir,
+		Templates:   map[string]string{"web": "settings web\n"},
+	}}
+	templates, err := Templates(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tmpl := range templates {
+		got = append(got, tmpl.Name+"="+strings.TrimSpace(tmpl.Text))
+	}
+	want := "default=file default, mail=file mail, web=settings web"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Templates() = %s, want %s", strings.Join(got, ", "), want)
+	}
+
+	if tmpl, err := GetTemplate(inst, ""); err != nil || tmpl.Source != filepath.Join(dir, "default.zone") {
+		t.Errorf("GetTemplate(\"\") = %+v, %v, want the default template file", tmpl, err)
+	}
+	if _, err := GetTemplate(inst, "notes"); err == nil {
+		t.Error("GetTemplate() found a file without the template extension")
+	}
+
+	inst.TemplateDir = filepath.Join(dir, "missing")
+	if tmpl, err := GetTemplate(inst, ""); err != nil || tmpl.Source != "builtin" {
+		t.Errorf("GetTemplate(\"\") without a template directory = %+v, %v, want the builtin template", tmpl, err)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	inst := &config.Instance{Profile: config.Profile{
+		TemplateDir: t.TempDir(),
+		Templates: map[string]string{
+			"no-ns": "$TTL 3600\n@ IN SOA {{.NS1}}. admin.{{.Domain}}. {{.Serial}} 1 2 3 4\n",
+		},
+	}}
+	if diags, err := CheckTemplate(inst, ""); err != nil || len(diags) != 0 {
+		t.Errorf("CheckTemplate() of the default template = %v, %v", diags, err)
+	}
+	diags, err := CheckTemplate(inst, "no-ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "no NS records") {
+		t.Errorf("CheckTemplate() = %v, want a missing NS problem", diags)
+	}
+}
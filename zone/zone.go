@@ -1,129 +1,321 @@
 package zone
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"path/filepath"
+	"slices"
+	"time"
 
+	"github.com/AfazTech/b9m/checker"
 	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/lock"
 	"github.com/AfazTech/b9m/parser"
+	"github.com/AfazTech/b9m/serial"
 	"github.com/AfazTech/b9m/servicemanager"
 	"github.com/AfazTech/b9m/utils"
 )
 
-func AddDomain(domain string, ns1 string, ns2 string) error {
+// DomainOptions describe a new primary domain. The first nameserver becomes
+// the SOA MNAME, and SOA overrides what the template sets.
+type DomainOptions struct {
+	Nameservers  []string          `json:"nameservers"`
+	SerialScheme serial.Scheme     `json:"serial_scheme,omitempty"`
+	Template     string            `json:"template,omitempty"`
+	SOA          parser.SOAOptions `json:"soa"`
+}
+
+// AddDomain adds a primary zone for domain whose zone file is produced by
+// the template named in opts, the default one if it is empty.
+func AddDomain(inst *config.Instance, view, domain string, opts DomainOptions) error {
 	if err := utils.ValidateDomain(domain); err != nil {
 		return fmt.Errorf("failed to add domain %s: %w", domain, err)
 	}
-	if err := utils.ValidateDomain(ns1); err != nil {
-		return fmt.Errorf("invalid nameserver domain for NS1 (%s): %w", ns1, err)
+	if err := utils.ValidateView(view); err != nil {
+		return fmt.Errorf("failed to add domain %s: %w", domain, err)
 	}
-	if err := utils.ValidateDomain(ns2); err != nil {
-		return fmt.Errorf("invalid nameserver domain for NS2 (%s): %w", ns2, err)
+	confLock, err := lock.Config(inst.Name)
+	if err != nil {
+		return err
 	}
-	exists, err := utils.DomainExists(domain)
+	defer confLock.Unlock()
+	zoneLock, err := lock.Zone(inst.Name, view, domain)
+	if err != nil {
+		return err
+	}
+	defer zoneLock.Unlock()
+	tx := utils.NewTransaction()
+	if err := addDomain(inst, tx, view, domain, opts); err != nil {
+		return tx.Abort(err)
+	}
+	if err := servicemanager.ReloadBind(inst); err != nil {
+		return tx.Abort(err)
+	}
+	return nil
+}
+
+// addDomain writes the zone file and zone statement of a new primary domain
+// as part of tx. The caller holds the config lock and the zone lock.
+func addDomain(inst *config.Instance, tx *utils.Transaction, view, domain string, opts DomainOptions) error {
+	if len(opts.Nameservers) == 0 {
+		return fmt.Errorf("failed to add domain %s: at least one nameserver is required", domain)
+	}
+	for i, ns := range opts.Nameservers {
+		if err := utils.ValidateDomain(ns); err != nil {
+			return fmt.Errorf("invalid nameserver domain for NS%d (%s): %w", i+1, ns, err)
+		}
+		if slices.Contains(opts.Nameservers[:i], ns) {
+			return fmt.Errorf("failed to add domain %s: duplicate nameserver %s", domain, ns)
+		}
+	}
+	if err := opts.SOA.Validate(); err != nil {
+		return fmt.Errorf("failed to add domain %s: %w", domain, err)
+	}
+	exists, err := utils.DomainExists(inst, view, domain)
 	if err != nil {
 		return fmt.Errorf("error checking existence of domain %s: %w", domain, err)
 	}
 	if exists {
-		return fmt.Errorf("domain already exists: %s", domain)
+		return fmt.Errorf("domain already exists: %s", parser.DomainName(view, domain))
 	}
-	if err := utils.ValidateARecord(ns1); err != nil {
-		return fmt.Errorf("failed to validate A record for NS1 (%s): %w", ns1, err)
+	scheme, err := serial.ParseScheme(string(opts.SerialScheme))
+	if err != nil {
+		return fmt.Errorf("failed to add domain %s: %w", domain, err)
 	}
-	if err := utils.ValidateARecord(ns2); err != nil {
-		return fmt.Errorf("failed to validate A record for NS2 (%s): %w", ns2, err)
+	tmpl, err := GetTemplate(inst, opts.Template)
+	if err != nil {
+		return fmt.Errorf("failed to add domain %s: %w", domain, err)
 	}
-
-	zoneFile := fmt.Sprintf("%s/%s.b9m", config.GetZoneDir(), domain)
-	record := fmt.Sprintf("$TTL 86400\n@ IN SOA %s. admin.%s. ( 2023100101 86400 3600 604800 86400 )\n", ns1, domain)
-	record += fmt.Sprintf("@ IN NS %s.\n", ns1)
-	record += fmt.Sprintf("@ IN NS %s.\n", ns2)
-
-	file, err := os.Create(zoneFile)
+	zf, err := tmpl.Render(newTemplateData(domain, opts.Nameservers, serial.Initial(scheme, time.Now())))
 	if err != nil {
-		return fmt.Errorf("failed to create zone file for domain %s: %w", domain, err)
+		return fmt.Errorf("failed to add domain %s: %w", domain, err)
 	}
-	defer file.Close()
-	if _, err := file.WriteString(record); err != nil {
+	if err := parser.FirstError(zf.Diagnostics); err != nil {
+		return fmt.Errorf("template %s produced an invalid zone for domain %s: %w", tmpl.Name, domain, err)
+	}
+	if err := zf.SetSOA(opts.SOA); err != nil {
+		return fmt.Errorf("failed to add domain %s: %w", domain, err)
+	}
+	if err := checker.CheckZone(domain, zf.Data()); err != nil {
+		return fmt.Errorf("refusing to add domain %s: %w", domain, err)
+	}
+	for i, ns := range opts.Nameservers {
+		if err := utils.ValidateARecord(ns); err != nil {
+			return fmt.Errorf("failed to validate A record for NS%d (%s): %w", i+1, ns, err)
+		}
+	}
+	zoneFile := filepath.Join(inst.ZoneDir, view, domain+".b9m")
+	if err := os.MkdirAll(filepath.Dir(zoneFile), 0755); err != nil {
+		return fmt.Errorf("failed to create zone directory for domain %s: %w", domain, err)
+	}
+	zf.Path = zoneFile
+	zf.SetSerialScheme(scheme)
+	if err := tx.WriteZone(zf); err != nil {
 		return fmt.Errorf("failed to write zone record to file %s: %w", zoneFile, err)
 	}
-
-	return addZone(domain)
+	return addZone(inst, tx, view, domain, zoneFile)
 }
 
-func DeleteDomain(domain string) error {
+func DeleteDomain(inst *config.Instance, view, domain string) error {
 	if err := utils.ValidateDomain(domain); err != nil {
 		return fmt.Errorf("failed to delete domain %s: %w", domain, err)
 	}
-	exists, err := utils.DomainExists(domain)
+	if err := utils.ValidateView(view); err != nil {
+		return fmt.Errorf("failed to delete domain %s: %w", domain, err)
+	}
+	confLock, err := lock.Config(inst.Name)
 	if err != nil {
-		return fmt.Errorf("error checking existence of domain %s: %w", domain, err)
+		return err
 	}
-	if !exists {
-		return fmt.Errorf("domain does not exist: %s", domain)
+	defer confLock.Unlock()
+	zoneLock, err := lock.Zone(inst.Name, view, domain)
+	if err != nil {
+		return err
 	}
-	domains, err := parser.GetDomains()
+	defer zoneLock.Unlock()
+	domains, err := parser.GetDomains(inst)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve domains for deletion of %s: %w", domain, err)
 	}
-	zoneFile, exists := domains[domain]
+	d, exists := parser.FindDomain(domains, view, domain)
 	if !exists {
-		return fmt.Errorf("zone file not found for domain: %s", domain)
+		return fmt.Errorf("domain does not exist: %s", parser.DomainName(view, domain))
+	}
+	zoneFile := d.File
+	tx := utils.NewTransaction()
+	if err := deleteZone(inst, tx, view, domain); err != nil {
+		return tx.Abort(err)
+	}
+	// Only primary zones must have a file; others may not have been
+	// transferred yet or have none at all.
+	if _, err := os.Stat(zoneFile); zoneFile != "" && (err == nil || d.IsPrimary()) {
+		if err := tx.Remove(zoneFile); err != nil {
+			return tx.Abort(fmt.Errorf("failed to remove zone file %s for domain %s: %w", zoneFile, domain, err))
+		}
+	}
+	if err := servicemanager.ReloadBind(inst); err != nil {
+		return tx.Abort(err)
+	}
+	return nil
+}
+
+func SetSerialScheme(inst *config.Instance, view, domain string, scheme serial.Scheme) error {
+	if err := utils.ValidateDomain(domain); err != nil {
+		return fmt.Errorf("failed to set serial scheme for domain %s: %w", domain, err)
+	}
+	scheme, err := serial.ParseScheme(string(scheme))
+	if err != nil {
+		return fmt.Errorf("failed to set serial scheme for domain %s: %w", domain, err)
+	}
+	zoneLock, err := lock.Zone(inst.Name, view, domain)
+	if err != nil {
+		return err
+	}
+	defer zoneLock.Unlock()
+	zf, err := parser.LoadDomainZone(inst, view, domain)
+	if err != nil {
+		return fmt.Errorf("failed to read zone file for domain %s: %w", domain, err)
+	}
+	if err := parser.FirstError(zf.Diagnostics); err != nil {
+		return fmt.Errorf("zone file for domain %s has errors: %w", domain, err)
 	}
-	if err := os.Remove(zoneFile); err != nil {
-		return fmt.Errorf("failed to remove zone file %s for domain %s: %w", zoneFile, domain, err)
+	zf.SetSerialScheme(scheme)
+	if _, err := zf.BumpSerial(time.Now()); err != nil {
+		return fmt.Errorf("failed to update SOA serial for domain %s: %w", domain, err)
 	}
-	return deleteZone(domain)
+	if err := checker.CheckZone(domain, zf.Data()); err != nil {
+		return fmt.Errorf("refusing to update zone of domain %s: %w", domain, err)
+	}
+	tx := utils.NewTransaction()
+	if err := tx.WriteZone(zf); err != nil {
+		return tx.Abort(fmt.Errorf("failed to write zone file for domain %s: %w", domain, err))
+	}
+	if err := servicemanager.ReloadBind(inst); err != nil {
+		return tx.Abort(err)
+	}
+	return nil
 }
 
-func deleteZone(domain string) error {
-	confFile := config.GetConfigFile()
-	data, err := os.ReadFile(confFile)
-	if err != nil {
-		return fmt.Errorf("failed to read configuration file %s: %w", confFile, err)
-	}
-	lines := strings.Split(string(data), "\n")
-	var newLines []string
-	zoneEntry := fmt.Sprintf("zone \"%s\" {", domain)
-	skipNextLines := false
-	for _, line := range lines {
-		if skipNextLines {
-			if line == "};" {
-				skipNextLines = false
+// CheckZone lints the zone file of domain, returning the parse diagnostics
+// followed by the problems named-checkzone would report for its contents.
+// In strict mode checking stops at the first error, which ends the list.
+func CheckZone(inst *config.Instance, view, domain string, strict bool) ([]parser.Diagnostic, error) {
+	if err := utils.ValidateDomain(domain); err != nil {
+		return nil, fmt.Errorf("failed to check domain %s: %w", domain, err)
+	}
+	zf, err := parser.LoadDomainZone(inst, view, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone file for domain %s: %w", domain, err)
+	}
+	diags := lintZone(domain, zf)
+	if strict {
+		for i, d := range diags {
+			if d.Severity == parser.SeverityError {
+				return diags[:i+1], nil
 			}
-			continue
 		}
-		if strings.HasPrefix(line, zoneEntry) {
-			skipNextLines = true
-			continue
+	}
+	return diags, nil
+}
+
+func lintZone(domain string, zf *parser.ZoneFile) []parser.Diagnostic {
+	diags := zf.Diagnostics
+	var checkErr *checker.Error
+	if err := checker.CheckZone(domain, zf.Data()); errors.As(err, &checkErr) {
+		for _, problem := range checkErr.Problems {
+			diags = append(diags, parser.Diagnostic{File: zf.Path, Severity: parser.SeverityError, Message: problem})
 		}
-		newLines = append(newLines, line)
 	}
-	if err := os.WriteFile(confFile, []byte(strings.Join(newLines, "\n")), 0644); err != nil {
-		return fmt.Errorf("failed to update configuration file after deleting zone for domain %s: %w", domain, err)
+	return diags
+}
+
+func SetZoneOption(inst *config.Instance, view, domain, option, value string) error {
+	if err := utils.ValidateDomain(domain); err != nil {
+		return fmt.Errorf("failed to set option %s for domain %s: %w", option, domain, err)
 	}
-	return servicemanager.ReloadBind()
+	confLock, err := lock.Config(inst.Name)
+	if err != nil {
+		return err
+	}
+	defer confLock.Unlock()
+	conf, err := parser.LoadConfig(inst)
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %w", inst.ConfigFile, err)
+	}
+	z := conf.FindZone(domain, view)
+	if z == nil {
+		return fmt.Errorf("zone for domain %s not found in configuration", parser.DomainName(view, domain))
+	}
+	if value == "" {
+		err = conf.UnsetOption(z.Stmt, option)
+	} else {
+		err = conf.SetOption(z.Stmt, option, value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update option %s of zone %s: %w", option, domain, err)
+	}
+	if err := checker.CheckConfig(conf); err != nil {
+		return fmt.Errorf("refusing to update option %s of zone %s: %w", option, domain, err)
+	}
+	tx := utils.NewTransaction()
+	if err := tx.WriteConfig(conf); err != nil {
+		return tx.Abort(fmt.Errorf("failed to update configuration file for domain %s: %w", domain, err))
+	}
+	if err := servicemanager.ReloadBind(inst); err != nil {
+		return tx.Abort(err)
+	}
+	return nil
 }
 
-func addZone(domain string) error {
-	confFile := config.GetConfigFile()
-	zoneDir := config.GetZoneDir()
-	zoneEntry := fmt.Sprintf("zone \"%s\" {\n\ttype master;\n\tfile \"%s/%s.b9m\";\n};\n", domain, zoneDir, domain)
-	data, err := os.ReadFile(config.GetConfigFile())
+func deleteZone(inst *config.Instance, tx *utils.Transaction, view, domain string) error {
+	conf, err := parser.LoadConfig(inst)
 	if err != nil {
-		return fmt.Errorf("failed to read configuration file %s: %w", confFile, err)
+		return fmt.Errorf("failed to parse configuration file %s: %w", inst.ConfigFile, err)
+	}
+	z := conf.FindZone(domain, view)
+	if z == nil {
+		return fmt.Errorf("zone for domain %s not found in configuration", parser.DomainName(view, domain))
+	}
+	if err := conf.RemoveStatement(z.Stmt); err != nil {
+		return fmt.Errorf("failed to remove zone for domain %s: %w", domain, err)
+	}
+	if err := checker.CheckConfig(conf); err != nil {
+		return fmt.Errorf("refusing to remove zone for domain %s: %w", domain, err)
 	}
-	if strings.Contains(string(data), domain) {
-		return fmt.Errorf("zone for domain %s already exists in configuration", domain)
+	if err := tx.WriteConfig(conf); err != nil {
+		return fmt.Errorf("failed to update configuration file after deleting zone for domain %s: %w", domain, err)
+	}
+	return nil
+}
+
+func addZone(inst *config.Instance, tx *utils.Transaction, view, domain, zoneFile string) error {
+	conf, err := parser.LoadConfig(inst)
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %w", inst.ConfigFile, err)
 	}
-	file, err := os.OpenFile(confFile, os.O_APPEND|os.O_WRONLY, 0644)
+	zoneFile, err = conf.NamedPath(zoneFile)
 	if err != nil {
-		return fmt.Errorf("failed to open configuration file %s for appending: %w", confFile, err)
+		return fmt.Errorf("failed to add zone entry for domain %s: %w", domain, err)
+	}
+	zoneEntry := fmt.Sprintf("zone %s {\n\ttype master;\n\tfile %s;\n};", parser.Quote(domain), parser.Quote(zoneFile))
+	if err := appendZone(conf, view, domain, zoneEntry); err != nil {
+		return fmt.Errorf("failed to add zone entry for domain %s: %w", domain, err)
 	}
-	defer file.Close()
-	if _, err := file.WriteString(zoneEntry); err != nil {
+	if err := checker.CheckConfig(conf); err != nil {
+		return fmt.Errorf("refusing to add zone for domain %s: %w", domain, err)
+	}
+	if err := tx.WriteConfig(conf); err != nil {
 		return fmt.Errorf("failed to write zone entry for domain %s to configuration file: %w", domain, err)
 	}
-	return servicemanager.ReloadBind()
+	return nil
+}
+
+func findView(conf *parser.Config, name string) *parser.View {
+	for _, v := range conf.Views() {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
 }
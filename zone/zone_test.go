@@ -0,0 +1,118 @@
+package zone
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AfazTech/b9m/config"
+	"github.com/AfazTech/b9m/lock"
+	"github.com/AfazTech/b9m/parser"
+)
+
+const testZone = "$TTL 3600\n" +
+	"@ IN SOA ns1.example.com. admin.example.com. 1 7200 3600 1209600 3600\n" +
+	"@ IN NS ns1.example.com.\n" +
+	"ns1 IN A 192.0.2.1\n"
+
+const viewsConf = "key \"xfr\" { algorithm hmac-sha256; secret \"c2VjcmV0\"; };\n" +
+	"view \"int\" {\n" +
+	"\tmatch-clients { 10.0.0.0/8; };\n" +
+	"\tzone \"example.com\" { type master; file \"int/example.com.db\"; };\n" +
+	"};\n" +
+	"view \"ext\" {\n" +
+	"\tmatch-clients { any; };\n" +
+	"\tzone \"example.com\" { type master; file \"ext/example.com.db\"; };\n" +
+	"};\n"
+
+// newTestInstance writes the files, keyed by path relative to the zone
+// directory, and returns an instance whose named.conf is conf. Reloads
+// always succeed.
+func newTestInstance(t *testing.T, conf string, files map[string]string) *config.Instance {
+	t.Helper()
+	dir := t.TempDir()
+	lock.SetDir(filepath.Join(dir, "locks"))
+	files["named.conf"] = conf
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &config.Instance{Name: config.DefaultProfile, Profile: config.Profile{
+		ConfigFile: filepath.Join(dir, "named.conf"),
+		ZoneDir:    dir,
+		Rndc:       "true",
+	}}
+}
+
+func readConf(t *testing.T, inst *config.Instance) *parser.Config {
+	t.Helper()
+	conf, err := parser.LoadConfig(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestViews(t *testing.T) {
+	inst := newTestInstance(t, viewsConf, map[string]string{
+		"int/example.com.db": testZone,
+		"ext/example.com.db": testZone,
+	})
+	domains, err := GetDomains(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 2 || domains[0].View != "int" || domains[1].View != "ext" {
+		t.Fatalf("GetDomains() = %+v, want example.com in views int and ext", domains)
+	}
+
+	if err := DeleteDomain(inst, "int", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	conf := readConf(t, inst)
+	if conf.FindZone("example.com", "int") != nil || conf.FindZone("example.com", "ext") == nil {
+		t.Error("DeleteDomain() did not remove example.com from view int only")
+	}
+	if _, err := os.Stat(filepath.Join(inst.ZoneDir, "int/example.com.db")); !os.IsNotExist(err) {
+		t.Errorf("zone file of view int not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(inst.ZoneDir, "ext/example.com.db")); err != nil {
+		t.Errorf("zone file of view ext removed: %v", err)
+	}
+
+	for _, view := range []string{"int", "missing"} {
+		if err := DeleteDomain(inst, view, "example.com"); err == nil {
+			t.Errorf("DeleteDomain() in view %s succeeded", view)
+		}
+	}
+	if err := SetZoneOption(inst, "ext", "example.com", "notify", "no"); err != nil {
+		t.Fatal(err)
+	}
+	if z := readConf(t, inst).FindZone("example.com", "ext"); z.Stmt.Find("notify") == nil {
+		t.Error("SetZoneOption() did not set notify in view ext")
+	}
+}
+
+func TestZoneOutsideViews(t *testing.T) {
+	inst := newTestInstance(t, viewsConf, map[string]string{})
+	before, err := os.ReadFile(inst.ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddForward(inst, "", "example.org", ForwardOptions{Forwarders: []string{"192.0.2.53"}})
+	if err == nil || !strings.Contains(err.Error(), "views") {
+		t.Errorf("AddForward() outside views = %v, want an error about views", err)
+	}
+	if err := AddForward(inst, "missing", "example.org", ForwardOptions{Forwarders: []string{"192.0.2.53"}}); err == nil {
+		t.Error("AddForward() to a missing view succeeded")
+	}
+	if after, _ := os.ReadFile(inst.ConfigFile); string(after) != string(before) {
+		t.Errorf("configuration changed by failed edits:\n%s", after)
+	}
+}